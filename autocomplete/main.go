@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"autocomplete/ollamastream"
@@ -13,6 +14,10 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// ollamaEndpoint is the Ollama generate endpoint. OLLAMA_ENDPOINT
+// overrides it.
+var ollamaEndpoint = "http://localhost:11434/api/generate"
+
 type suggestionMsg struct {
 	suggestion string
 	err        error
@@ -20,8 +25,6 @@ type suggestionMsg struct {
 
 func generate(prompt string) tea.Cmd {
 	return func() tea.Msg {
-		const ollamaEndpoint = "http://localhost:11434/api/generate"
-
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -42,7 +45,6 @@ func generate(prompt string) tea.Cmd {
 			func(token string) { suggestionString += token },
 		)
 		if err != nil {
-			log.Fatalf("GenerateStream error: %v", err)
 			return suggestionMsg{err: err}
 		}
 
@@ -51,6 +53,9 @@ func generate(prompt string) tea.Cmd {
 }
 
 func main() {
+	if endpoint := os.Getenv("OLLAMA_ENDPOINT"); endpoint != "" {
+		ollamaEndpoint = endpoint
+	}
 	p := tea.NewProgram(initialModel())
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
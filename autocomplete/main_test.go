@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fakeOllama streams reply as tokens a word at a time, or fails with status
+// if it is not 200.
+func fakeOllama(t *testing.T, status int, reply string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, reply, status)
+			return
+		}
+		for i, word := range strings.Fields(reply) {
+			if i > 0 {
+				word = " " + word
+			}
+			fmt.Fprintf(w, "{\"response\":%q}\n", word)
+		}
+		fmt.Fprintln(w, `{"done":true}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	old := ollamaEndpoint
+	ollamaEndpoint = srv.URL
+	t.Cleanup(func() { ollamaEndpoint = old })
+	lipgloss.SetColorProfile(termenv.Ascii)
+}
+
+// tui drives the model synchronously, running each command to completion
+// before the next key is sent.
+type tui struct {
+	t    *testing.T
+	m    model
+	quit bool
+}
+
+func newTUI(t *testing.T) *tui {
+	tm := &tui{t: t, m: initialModel()}
+	tm.run(tm.m.Init())
+	return tm
+}
+
+func (tm *tui) send(msg tea.Msg) {
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		msg, queue = queue[0], queue[1:]
+		if _, ok := msg.(tea.QuitMsg); ok {
+			tm.quit = true
+			continue
+		}
+		next, cmd := tm.m.Update(msg)
+		tm.m = next.(model)
+		queue = append(queue, collect(cmd)...)
+	}
+}
+
+func (tm *tui) run(cmd tea.Cmd) {
+	for _, msg := range collect(cmd) {
+		tm.send(msg)
+	}
+}
+
+// collect runs cmd and the commands of any batch it returns. Cursor blinks
+// are dropped before they run, as they wait for the blink interval.
+func collect(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil || strings.Contains(runtime.FuncForPC(reflect.ValueOf(cmd).Pointer()).Name(), "/bubbles/cursor.") {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, collect(c)...)
+		}
+		return msgs
+	}
+	if msg == nil || strings.HasSuffix(reflect.TypeOf(msg).PkgPath(), "/bubbles/cursor") {
+		return nil
+	}
+	return []tea.Msg{msg}
+}
+
+func (tm *tui) typeText(text string) {
+	for _, r := range text {
+		tm.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func (tm *tui) press(k tea.KeyType) {
+	tm.send(tea.KeyMsg{Type: k})
+}
+
+// golden compares the view with testdata/<name>.golden, or rewrites the
+// file when the tests run with -update.
+func (tm *tui) golden(name string) {
+	tm.t.Helper()
+	got := tm.m.View() + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			tm.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			tm.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tm.t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		tm.t.Errorf("view does not match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestSuggestion(t *testing.T) {
+	fakeOllama(t, http.StatusOK, "brown fox jumps")
+	tm := newTUI(t)
+	tm.golden("empty")
+
+	tm.typeText("The quick ")
+	tm.golden("suggestion")
+
+	tm.press(tea.KeyTab)
+	tm.golden("accepted")
+	if got := tm.m.textInput.Value(); got != "The quick brown fox jumps" {
+		t.Errorf("input = %q after accepting", got)
+	}
+}
+
+func TestSuggestionError(t *testing.T) {
+	fakeOllama(t, http.StatusNotFound, `model "llama3.2" not found`)
+	tm := newTUI(t)
+
+	tm.typeText("Hi")
+	tm.golden("error")
+}
+
+func TestQuit(t *testing.T) {
+	fakeOllama(t, http.StatusOK, "")
+	tm := newTUI(t)
+
+	tm.press(tea.KeyEsc)
+	if !tm.quit {
+		t.Error("esc did not quit")
+	}
+}
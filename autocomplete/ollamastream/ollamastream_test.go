@@ -0,0 +1,52 @@
+package ollamastream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateStream(t *testing.T) {
+	var got requestBody
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		fmt.Fprintln(w, `{"response":"Hello"}`)
+		fmt.Fprintln(w, ``)
+		fmt.Fprintln(w, `{"response":", world"}`)
+		fmt.Fprintln(w, `{"done":true}`)
+		fmt.Fprintln(w, `{"response":" ignored"}`)
+	}))
+	defer srv.Close()
+
+	var tokens []string
+	err := GenerateStream(context.Background(), "Say hello", srv.URL, "llama3.2", 0.5, 20, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello|, world"; strings.Join(tokens, "|") != want {
+		t.Errorf("tokens = %q, want %q", strings.Join(tokens, "|"), want)
+	}
+	if got.Prompt != "Say hello" || got.Model != "llama3.2" || got.Options.Temperature != 0.5 || got.Options.MaxTokens != 20 {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestGenerateStreamStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `model "nope" not found`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	err := GenerateStream(context.Background(), "Say hello", srv.URL, "nope", 0.5, 20, nil)
+	if err == nil || !strings.Contains(err.Error(), `404: model "nope" not found`) {
+		t.Errorf("err = %v, want the status and message", err)
+	}
+}
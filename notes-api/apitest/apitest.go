@@ -0,0 +1,169 @@
+// Package apitest runs the notes-api router against a temporary SQLite
+// database for integration tests.
+//
+//	srv := apitest.New(t)
+//	srv.Load("testdata/notes.json")
+//	resp := srv.Do(http.MethodGet, "/notes", nil)
+//	resp.Expect(http.StatusOK)
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"notes-api/db"
+	"notes-api/models"
+	"notes-api/repository"
+	"notes-api/routes"
+
+	"gorm.io/gorm/logger"
+)
+
+// Server is the notes-api router served by httptest. Each server has a
+// database of its own, but db.DB is global, so tests using it must not run
+// in parallel.
+type Server struct {
+	*httptest.Server
+	t testing.TB
+}
+
+// New starts a server on a fresh database. Both are closed when the test
+// ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+	if err := db.Open(filepath.Join(t.TempDir(), "notes.db")); err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	db.DB.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	s := &Server{Server: httptest.NewServer(routes.SetupRouter()), t: t}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Fixtures is the format of fixture files. Records are stored as given, so
+// fixtures can fix IDs, slugs and timestamps; notes without a slug get one
+// from their title.
+type Fixtures struct {
+	Notes     []models.Note     `json:"notes"`
+	Templates []models.Template `json:"templates"`
+}
+
+// Load stores the fixtures of a JSON file in the database.
+func (s *Server) Load(path string) Fixtures {
+	s.t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("reading fixtures: %v", err)
+	}
+	var f Fixtures
+	if err := json.Unmarshal(data, &f); err != nil {
+		s.t.Fatalf("decoding fixtures %s: %v", path, err)
+	}
+	repo := repository.New(db.DB)
+	for i, n := range f.Notes {
+		if err := repo.CreateNote(&f.Notes[i]); err != nil {
+			s.t.Fatalf("loading note %s: %v", n.ID, err)
+		}
+	}
+	for i, t := range f.Templates {
+		if err := repo.CreateTemplate(&f.Templates[i]); err != nil {
+			s.t.Fatalf("loading template %s: %v", t.ID, err)
+		}
+	}
+	return f
+}
+
+// Response is a response read in full.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	t          testing.TB
+}
+
+// Do sends a request to the server. A string or []byte body is sent as is,
+// anything else as JSON.
+func (s *Server) Do(method, path string, body any) *Response {
+	s.t.Helper()
+	return s.Send(method, path, nil, body)
+}
+
+// Send is Do with extra request headers, such as Accept.
+func (s *Server) Send(method, path string, header http.Header, body any) *Response {
+	s.t.Helper()
+	resp, err := s.Try(method, path, header, body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp
+}
+
+// Try is Send returning an error instead of failing the test, so it can be
+// called from goroutines other than the test's own.
+func (s *Server) Try(method, path string, header http.Header, body any) (*Response, error) {
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = bytes.NewBufferString(b)
+	case []byte:
+		r = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request body: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: reading response: %w", method, path, err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: data, t: s.t}, nil
+}
+
+// Expect fails the test unless the response has the given status.
+func (r *Response) Expect(status int) *Response {
+	r.t.Helper()
+	if r.StatusCode != status {
+		r.t.Fatalf("status %d, want %d: %s", r.StatusCode, status, bytes.TrimSpace(r.Body))
+	}
+	return r
+}
+
+// Decode decodes the JSON body into v.
+func (r *Response) Decode(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("decoding %q: %v", r.Body, err)
+	}
+}
@@ -2,21 +2,35 @@ package db
 
 import (
 	"notes-api/models"
+	"notes-api/repository"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// busyTimeout is how long, in milliseconds, a write waits for another to
+// finish instead of failing with "database is locked".
+const busyTimeout = "5000"
+
 var DB *gorm.DB
 
 func InitDB() error {
+	return Open("notes.db")
+}
+
+// Open connects DB to the SQLite database at path and migrates it,
+// including giving slugs to notes stored before notes had them.
+func Open(path string) error {
 	var err error
-	DB, err = gorm.Open(sqlite.Open("notes.db"), &gorm.Config{})
+	// TranslateError turns unique constraint violations into
+	// gorm.ErrDuplicatedKey, which the repository reports as ErrDuplicate.
+	DB, err = gorm.Open(sqlite.Open(path+"?_busy_timeout="+busyTimeout), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return err
 	}
 
-	DB.AutoMigrate(&models.Note{})
-
-	return nil
+	if err := DB.AutoMigrate(&models.Note{}, &models.Template{}, &models.Change{}, &models.IdempotencyKey{}); err != nil {
+		return err
+	}
+	return repository.New(DB).BackfillSlugs()
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"notes-api/models"
+	"notes-api/repository"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+type calendarResponse struct {
+	Month string   `json:"month"`
+	Days  []string `json:"days"`
+}
+
+func GetDaily(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	note, ok := findOrCreateDaily(w, chi.URLParam(r, "date"))
+	if !ok {
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
+}
+
+func UpdateDaily(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	note, ok := findOrCreateDaily(w, chi.URLParam(r, "date"))
+	if !ok {
+		return
+	}
+	id, day, slug, createdAt := note.ID, note.Day, note.Slug, note.CreatedAt
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		http.Error(w, "Invalid note: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	note.ID, note.Day, note.Slug, note.CreatedAt = id, day, slug, createdAt
+	if note.Encrypted {
+		note.Summary = ""
+	}
+
+	// Save writes zero values too, so clients can clear the content.
+	if err := repo().SaveNote(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
+}
+
+// GetCalendar lists the days of a month that have a non-empty daily note.
+// The month defaults to the current one.
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	month := r.URL.Query().Get("month")
+	if month == "" {
+		month = time.Now().Format(monthLayout)
+	}
+	if _, err := time.Parse(monthLayout, month); err != nil {
+		http.Error(w, "Invalid month, expected yyyy-mm", http.StatusBadRequest)
+		return
+	}
+
+	days, err := repo().DailyDays(month)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, calendarResponse{Month: month, Days: days})
+}
+
+// findOrCreateDaily returns the daily note for date, creating an empty one
+// the first time the date is requested.
+func findOrCreateDaily(w http.ResponseWriter, date string) (models.Note, bool) {
+	if _, err := time.Parse(dayLayout, date); err != nil {
+		http.Error(w, "Invalid date, expected yyyy-mm-dd", http.StatusBadRequest)
+		return models.Note{}, false
+	}
+
+	note, err := repo().DailyNote(date)
+	if errors.Is(err, repository.ErrNotFound) {
+		note, err = repo().CreateDailyNote(models.Note{ID: uuid.New().String(), Title: date, Day: date})
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return note, false
+	}
+	return note, true
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"notes-api/models"
+	"notes-api/repository"
+)
+
+// IdempotencyTTL is how long a response is kept for replay to requests with
+// the same Idempotency-Key.
+var IdempotencyTTL = 24 * time.Hour
+
+const maxIdempotencyKey = 255
+
+// Idempotent lets clients retry a request safely by sending an
+// Idempotency-Key header. The first request with a key runs as usual and a
+// successful response is stored; retries with the same key, Accept and body
+// get that response again, marked with Idempotent-Replayed, instead of
+// running the request twice. The same key with a different Accept or body
+// is refused with 422, and a retry while the first request is still running
+// with 409. Failed requests, panics included, are not stored, so they can
+// be retried with their key.
+func Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			http.Error(w, "Invalid Idempotency-Key", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(r, body)
+
+		if err := repo().ExpireIdempotencyKeys(time.Now().Add(-IdempotencyTTL)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reserved, err := repo().ReserveIdempotencyKey(&models.IdempotencyKey{Key: key, RequestHash: hash})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !reserved {
+			replay(w, key, hash)
+			return
+		}
+
+		// A panicking handler must not leave the key reserved, or every
+		// retry would be told the request is in progress until it expires.
+		defer func() {
+			if v := recover(); v != nil {
+				releaseIdempotencyKey(key)
+				panic(v)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status < 200 || rec.status >= 300 {
+			releaseIdempotencyKey(key)
+			return
+		}
+		err = repo().CompleteIdempotencyKey(&models.IdempotencyKey{
+			Key:         key,
+			Status:      rec.status,
+			ContentType: w.Header().Get("Content-Type"),
+			Location:    w.Header().Get("Location"),
+			Body:        rec.body.Bytes(),
+		})
+		if err != nil {
+			// The response is sent already; a retry will be told the
+			// request is in progress until the key expires.
+			log.Println("Storing idempotent response:", err)
+		}
+	})
+}
+
+// releaseIdempotencyKey forgets a reserved key, so it can be used again.
+func releaseIdempotencyKey(key string) {
+	if err := repo().DeleteIdempotencyKey(key); err != nil {
+		log.Println("Releasing idempotency key:", err)
+	}
+}
+
+// replay answers a request whose key is stored already.
+func replay(w http.ResponseWriter, key, hash string) {
+	k, err := repo().IdempotencyKey(key)
+	if errors.Is(err, repository.ErrNotFound) {
+		// The first request failed and released the key in the meantime.
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if k.RequestHash != hash {
+		http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if k.Status == 0 {
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+
+	if k.ContentType != "" {
+		w.Header().Set("Content-Type", k.ContentType)
+	}
+	if k.Location != "" {
+		w.Header().Set("Location", k.Location)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(k.Status)
+	w.Write(k.Body)
+}
+
+// requestHash identifies a request by its method, URL, Accept and body.
+// Accept is included because it picks the representation that is stored.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	io.WriteString(h, "Accept: "+r.Header.Get("Accept")+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes a response through while keeping a copy of its status and
+// body.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"notes-api/models"
+)
+
+// markdown renders a note as Markdown with a front matter block holding
+// its metadata, e.g.
+//
+//	---
+//	id: 5b1f7c2e-…
+//	slug: groceries
+//	tags: [home]
+//	created_at: 2025-03-03T09:00:00Z
+//	updated_at: 2025-03-03T09:00:00Z
+//	---
+//
+//	# Groceries
+//
+//	Milk
+//
+// The content of encrypted notes is the client's ciphertext.
+func markdown(n models.Note) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %s\n", n.ID)
+	if n.Slug != "" {
+		fmt.Fprintf(&b, "slug: %s\n", n.Slug)
+	}
+	if n.Day != "" {
+		fmt.Fprintf(&b, "day: %s\n", n.Day)
+	}
+	if len(n.Tags) > 0 {
+		fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(n.Tags, ", "))
+	}
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{{"pinned", n.Pinned}, {"archived", n.Archived}, {"favorite", n.Favorite}, {"encrypted", n.Encrypted}} {
+		if flag.set {
+			fmt.Fprintf(&b, "%s: true\n", flag.name)
+		}
+	}
+	fmt.Fprintf(&b, "created_at: %s\n", n.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "updated_at: %s\n", n.UpdatedAt.UTC().Format(time.RFC3339))
+	b.WriteString("---\n")
+
+	if n.Title != "" {
+		fmt.Fprintf(&b, "\n# %s\n", n.Title)
+	}
+	if n.Summary != "" {
+		fmt.Fprintf(&b, "\n> %s\n", strings.ReplaceAll(n.Summary, "\n", "\n> "))
+	}
+	if n.Content != "" {
+		b.WriteString("\n" + strings.TrimRight(n.Content, "\n") + "\n")
+	}
+	return b.String()
+}
@@ -4,71 +4,212 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
-	"notes-api/db"
+	"notes-api/llm"
 	"notes-api/models"
+	"notes-api/repository"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
+// AutoTitle enables generating a title and summary for notes created
+// without a title.
+var AutoTitle bool
+
+// CreateNote stores a new note, or one made from the template named by
+// ?template=, and answers 201 with its URL in Location.
 func CreateNote(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("template") != "" {
+		createNoteFromTemplate(w, r, media)
+		return
+	}
+
 	var note models.Note
 
-	json.NewDecoder(r.Body).Decode(&note)
-	note.ID = uuid.New().String()
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		http.Error(w, "Invalid note: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	note.ID, note.Slug = uuid.New().String(), ""
+	if AutoTitle && note.Indexable() && strings.TrimSpace(note.Title) == "" && strings.TrimSpace(note.Content) != "" {
+		autoTitle(r, &note)
+	}
 	log.Println("Creating note with ID:", note.ID)
 	log.Println("Creating note with title:", note.Title)
 	log.Println(note)
 
-	db.DB.Create(&note)
-	json.NewEncoder(w).Encode(note)
+	if err := repo().CreateNote(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCreated(w, media, note)
 }
 
-func GetNotes(w http.ResponseWriter, r *http.Request) {
-	var notes []models.Note
-	db.DB.Find(&notes)
-	json.NewEncoder(w).Encode(notes)
+// writeCreated answers 201 with the URL of a new note in Location.
+func writeCreated(w http.ResponseWriter, media string, note models.Note) {
+	w.Header().Set("Location", "/notes/"+note.ID)
+	writeNote(w, media, http.StatusCreated, note)
 }
 
-func GetNote(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var note models.Note
-	if err := db.DB.First(&note, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			http.Error(w, "Note not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+// GetNotes lists notes with pinned notes first. Archived notes are hidden
+// unless ?archived=true is given, which lists only archived notes;
+// ?favorite=true limits the list to favorites. ?updated_since=<RFC 3339>
+// returns every note, archived or not, changed after the given time so
+// clients can sync incrementally.
+func GetNotes(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter := repository.NoteFilter{
+		Archived:     q.Get("archived") == "true",
+		FavoriteOnly: q.Get("favorite") == "true",
+	}
+	if since := q.Get("updated_since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			http.Error(w, "Invalid updated_since, expected RFC 3339", http.StatusBadRequest)
+			return
 		}
+		filter.UpdatedSince = t
+	}
+	notes, err := repo().Notes(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	writeNoteList(w, media, http.StatusOK, notes)
+}
 
-	json.NewEncoder(w).Encode(note)
+// GetNote returns the note whose ID or slug is {id}.
+func GetNote(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	note, err := repo().NoteByIDOrSlug(chi.URLParam(r, "id"))
+	if err != nil {
+		lookupError(w, err, "Note")
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
 }
 
 func UpdateNote(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
 	id := chi.URLParam(r, "id")
-	var note models.Note
-	if err := db.DB.Where("id = ?", id).First(&note).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			http.Error(w, "Note not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+	note, err := repo().Note(id)
+	if err != nil {
+		lookupError(w, err, "Note")
 		return
 	}
-	json.NewDecoder(r.Body).Decode(&note)
-	db.DB.Where("id = ?", id).Updates(&note)
-	json.NewEncoder(w).Encode(note)
+	createdAt, slug := note.CreatedAt, note.Slug
+	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+		http.Error(w, "Invalid note: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The slug is the server's, like the ID, so links to the note keep
+	// working.
+	note.ID, note.CreatedAt, note.Slug = id, createdAt, slug
+	if note.Encrypted {
+		// A plaintext summary would leak the encrypted content.
+		note.Summary = ""
+	}
+	// Save writes zero values too, so clients can clear fields such as
+	// content or the encrypted marker.
+	if err := repo().SaveNote(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
 }
 
+// DeleteNote answers 204, or 404 if there is no such note.
 func DeleteNote(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	var note models.Note
-	if err := db.DB.Where("id = ?", id).Delete(&note).Error; err != nil {
+	if err := repo().DeleteNote(chi.URLParam(r, "id")); err != nil {
+		lookupError(w, err, "Note")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TogglePinned(w http.ResponseWriter, r *http.Request) {
+	toggleFlag(w, r, repository.Pinned)
+}
+
+func ToggleArchived(w http.ResponseWriter, r *http.Request) {
+	toggleFlag(w, r, repository.Archived)
+}
+
+func ToggleFavorite(w http.ResponseWriter, r *http.Request) {
+	toggleFlag(w, r, repository.Favorite)
+}
+
+func toggleFlag(w http.ResponseWriter, r *http.Request, flag repository.Flag) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	note, err := repo().ToggleNote(chi.URLParam(r, "id"), flag)
+	if err != nil {
+		lookupError(w, err, "Note")
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
+}
+
+func SummarizeNote(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	note, err := repo().Note(chi.URLParam(r, "id"))
+	if err != nil {
+		lookupError(w, err, "Note")
+		return
+	}
+
+	if !note.Indexable() {
+		http.Error(w, "Encrypted notes cannot be summarized", http.StatusConflict)
+		return
+	}
+
+	summary, err := llm.Summarize(r.Context(), note.Title, note.Content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	note.Summary = summary
+	if err := repo().SetSummary(&note); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode("Note deleted successfully")
+	writeNote(w, media, http.StatusOK, note)
+}
+
+func autoTitle(r *http.Request, note *models.Note) {
+	title, err := llm.Title(r.Context(), note.Content)
+	if err != nil {
+		log.Println("Error generating title:", err)
+		return
+	}
+	note.Title = title
+
+	summary, err := llm.Summarize(r.Context(), title, note.Content)
+	if err != nil {
+		log.Println("Error generating summary:", err)
+		return
+	}
+	note.Summary = summary
 }
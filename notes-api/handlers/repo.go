@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"notes-api/db"
+	"notes-api/repository"
+)
+
+// repo returns the repository of the server's database.
+func repo() repository.Repo {
+	return repository.New(db.DB)
+}
+
+// lookupError reports a failed lookup: 404 with "<what> not found" when
+// there is no such record, 500 otherwise.
+func lookupError(w http.ResponseWriter, err error, what string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, what+" not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes-api/models"
+)
+
+const (
+	mediaJSON     = "application/json"
+	mediaMarkdown = "text/markdown"
+)
+
+// writeJSON sends v as JSON with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", mediaJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// negotiate picks the media type of a response holding notes, JSON or
+// Markdown, from the Accept header. JSON is the default. When neither is
+// acceptable it answers 406 and reports false, so handlers call it before
+// changing anything.
+func negotiate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	media := preferred(r.Header.Get("Accept"), mediaJSON, mediaMarkdown)
+	if media == "" {
+		http.Error(w, "Not acceptable, notes are available as "+mediaJSON+" or "+mediaMarkdown, http.StatusNotAcceptable)
+		return "", false
+	}
+	return media, true
+}
+
+// preferred returns the offered media type the Accept header rates
+// highest, the first offer on a tie, or "" if it accepts none of them.
+func preferred(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// quality is the q-value the Accept header gives a media type, taken from
+// its most specific matching range.
+func quality(accept, media string) float64 {
+	typ, _, _ := strings.Cut(media, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		rng := strings.ToLower(strings.TrimSpace(params[0]))
+		s := -1
+		switch rng {
+		case media:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+	}
+	return q
+}
+
+// writeNote sends a note as JSON or Markdown.
+func writeNote(w http.ResponseWriter, media string, status int, note models.Note) {
+	if media == mediaMarkdown {
+		writeMarkdown(w, status, note)
+		return
+	}
+	writeJSON(w, status, note)
+}
+
+// writeNoteList sends notes as a JSON array or as Markdown, one note after
+// the other.
+func writeNoteList(w http.ResponseWriter, media string, status int, notes []models.Note) {
+	if media == mediaMarkdown {
+		writeMarkdown(w, status, notes...)
+		return
+	}
+	writeJSON(w, status, notes)
+}
+
+func writeMarkdown(w http.ResponseWriter, status int, notes ...models.Note) {
+	w.Header().Set("Content-Type", mediaMarkdown+"; charset=utf-8")
+	w.WriteHeader(status)
+	for i, n := range notes {
+		if i > 0 {
+			w.Write([]byte("\n"))
+		}
+		w.Write([]byte(markdown(n)))
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+const searchLimit = 50
+
+// SearchNotes returns notes whose title or content contains every word of
+// ?q=, case-insensitively. Encrypted notes are never indexed and archived
+// notes are left out.
+func SearchNotes(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	words := strings.Fields(r.URL.Query().Get("q"))
+	if len(words) == 0 {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := repo().SearchNotes(words, searchLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNoteList(w, media, http.StatusOK, notes)
+}
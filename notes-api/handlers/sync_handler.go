@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notes-api/models"
+	"notes-api/repository"
+
+	"github.com/google/uuid"
+)
+
+type tombstone struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type syncResponse struct {
+	Created []models.Note `json:"created"`
+	Updated []models.Note `json:"updated"`
+	Deleted []tombstone   `json:"deleted"`
+	Token   string        `json:"token"`
+}
+
+// syncChange is one queued client change. For creates, ID is the client's
+// temporary ID and is echoed back as client_id. Note holds the fields to
+// set, with the same semantics as PUT /notes/{id}.
+type syncChange struct {
+	Op   string          `json:"op"`
+	ID   string          `json:"id"`
+	Note json.RawMessage `json:"note"`
+}
+
+type syncRequest struct {
+	Changes []syncChange `json:"changes"`
+}
+
+type syncResult struct {
+	Op       string       `json:"op"`
+	ID       string       `json:"id"`
+	ClientID string       `json:"client_id,omitempty"`
+	Note     *models.Note `json:"note,omitempty"`
+}
+
+type syncUploadResponse struct {
+	Results []syncResult `json:"results"`
+	Token   string       `json:"token"`
+}
+
+// errSyncRejected wraps errors caused by the uploaded changes rather than the
+// server.
+var errSyncRejected = errors.New("sync rejected")
+
+// GetSync returns the notes created, updated and deleted after the change
+// token in ?since= (0 or absent for everything), plus the token to pass next
+// time.
+func GetSync(w http.ResponseWriter, r *http.Request) {
+	since, err := parseToken(r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, "Invalid change token", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := repo().Changes(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := syncResponse{
+		Created: []models.Note{},
+		Updated: []models.Note{},
+		Deleted: []tombstone{},
+		Token:   strconv.FormatUint(since, 10),
+	}
+	if len(changes) == 0 {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.Token = strconv.FormatUint(changes[len(changes)-1].Seq, 10)
+
+	// Collapse the changes per note: a note created in the window is
+	// reported as created even if it was updated afterwards, and the last
+	// change wins otherwise.
+	created := map[string]bool{}
+	last := map[string]models.Change{}
+	var order []string
+	for _, c := range changes {
+		if _, ok := last[c.NoteID]; !ok {
+			order = append(order, c.NoteID)
+		}
+		if c.Op == models.ChangeCreate {
+			created[c.NoteID] = true
+		}
+		last[c.NoteID] = c
+	}
+
+	notes, err := repo().NotesByID(order)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := map[string]models.Note{}
+	for _, n := range notes {
+		byID[n.ID] = n
+	}
+
+	for _, id := range order {
+		note, exists := byID[id]
+		switch {
+		case !exists:
+			resp.Deleted = append(resp.Deleted, tombstone{ID: id, DeletedAt: last[id].CreatedAt})
+		case created[id]:
+			resp.Created = append(resp.Created, note)
+		default:
+			resp.Updated = append(resp.Updated, note)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// PostSync applies a batch of queued client changes in one transaction:
+// either all of them are stored or none are.
+func PostSync(w http.ResponseWriter, r *http.Request) {
+	var req syncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid sync request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := syncUploadResponse{Results: []syncResult{}}
+	err := repo().Transaction(func(tx repository.Repo) error {
+		ids := map[string]string{}
+		for i, c := range req.Changes {
+			if id, ok := ids[c.ID]; ok {
+				c.ID = id
+			}
+			result, err := applySyncChange(tx, c)
+			if err != nil {
+				return fmt.Errorf("change %d: %w", i, err)
+			}
+			if result.ClientID != "" {
+				ids[result.ClientID] = result.ID
+			}
+			resp.Results = append(resp.Results, result)
+		}
+
+		token, err := tx.LastChange()
+		resp.Token = strconv.FormatUint(token, 10)
+		return err
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSyncRejected) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func applySyncChange(tx repository.Repo, c syncChange) (syncResult, error) {
+	result := syncResult{Op: c.Op, ID: c.ID}
+
+	switch c.Op {
+	case models.ChangeCreate:
+		var note models.Note
+		if err := decodeSyncNote(c.Note, &note); err != nil {
+			return result, err
+		}
+		note.ID, note.Slug = uuid.New().String(), ""
+		if err := tx.CreateNote(&note); err != nil {
+			return result, err
+		}
+		result.ClientID, result.ID, result.Note = c.ID, note.ID, &note
+
+	case models.ChangeUpdate:
+		note, err := tx.Note(c.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return result, fmt.Errorf("%w: note %s not found", errSyncRejected, c.ID)
+		}
+		if err != nil {
+			return result, err
+		}
+		createdAt, slug := note.CreatedAt, note.Slug
+		if err := decodeSyncNote(c.Note, &note); err != nil {
+			return result, err
+		}
+		note.ID, note.CreatedAt, note.Slug = c.ID, createdAt, slug
+		if note.Encrypted {
+			note.Summary = ""
+		}
+		if err := tx.SaveNote(&note); err != nil {
+			return result, err
+		}
+		result.Note = &note
+
+	case models.ChangeDelete:
+		// Deleting a note twice, e.g. from two clients, is not an error.
+		if err := tx.DeleteNote(c.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return result, err
+		}
+
+	default:
+		return result, fmt.Errorf("%w: unknown op %q", errSyncRejected, c.Op)
+	}
+	return result, nil
+}
+
+func decodeSyncNote(raw json.RawMessage, note *models.Note) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, note); err != nil {
+		return fmt.Errorf("%w: invalid note: %v", errSyncRejected, err)
+	}
+	return nil
+}
+
+func parseToken(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(token, 10, 64)
+}
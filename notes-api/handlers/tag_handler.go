@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// tagRequest lists the tags to add to and remove from a note.
+type tagRequest struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TagNote adds and removes tags. Tags are trimmed, kept sorted and stored
+// once each; they are plaintext even on encrypted notes.
+func TagNote(w http.ResponseWriter, r *http.Request) {
+	media, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid tag request", http.StatusBadRequest)
+		return
+	}
+
+	note, err := repo().Note(id)
+	if err != nil {
+		lookupError(w, err, "Note")
+		return
+	}
+
+	tags := map[string]bool{}
+	for _, t := range note.Tags {
+		tags[t] = true
+	}
+	for _, t := range req.Add {
+		if t = strings.TrimSpace(t); t != "" {
+			tags[t] = true
+		}
+	}
+	for _, t := range req.Remove {
+		delete(tags, strings.TrimSpace(t))
+	}
+	note.Tags = make([]string, 0, len(tags))
+	for t := range tags {
+		note.Tags = append(note.Tags, t)
+	}
+	slices.Sort(note.Tags)
+
+	if err := repo().SetTags(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeNote(w, media, http.StatusOK, note)
+}
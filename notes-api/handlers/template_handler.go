@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"notes-api/models"
+	"notes-api/repository"
+	"notes-api/tmpl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type templateRequest struct {
+	Title string            `json:"title"`
+	User  string            `json:"user"`
+	Vars  map[string]string `json:"vars"`
+}
+
+func CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var t models.Template
+
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if t.Name == "" {
+		http.Error(w, "Template name is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateTemplate(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t.ID = uuid.New().String()
+
+	if err := repo().CreateTemplate(&t); err != nil {
+		templateWriteError(w, err, t)
+		return
+	}
+	w.Header().Set("Location", "/templates/"+t.ID)
+	withPrompts(&t)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func GetTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := repo().Templates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range templates {
+		withPrompts(&templates[i])
+	}
+	writeJSON(w, http.StatusOK, templates)
+}
+
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := findTemplate(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	withPrompts(&t)
+	writeJSON(w, http.StatusOK, t)
+}
+
+func UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := findTemplate(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	id, created := t.ID, t.CreatedAt
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	t.ID, t.CreatedAt = id, created
+	if t.Name == "" {
+		http.Error(w, "Template name is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateTemplate(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := repo().SaveTemplate(&t); err != nil {
+		templateWriteError(w, err, t)
+		return
+	}
+	withPrompts(&t)
+	writeJSON(w, http.StatusOK, t)
+}
+
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := repo().DeleteTemplate(chi.URLParam(r, "id")); err != nil {
+		lookupError(w, err, "Template")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createNoteFromTemplate instantiates the template named by the "template"
+// query parameter, which may be either its ID or its name.
+func createNoteFromTemplate(w http.ResponseWriter, r *http.Request, media string) {
+	t, ok := findTemplate(w, r.URL.Query().Get("template"))
+	if !ok {
+		return
+	}
+
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid template request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	data := tmpl.NewData(req.User, req.Vars, time.Now())
+
+	note := models.Note{ID: uuid.New().String(), Title: req.Title}
+	var err error
+	if note.Title == "" {
+		if note.Title, err = tmpl.Execute("title", t.Title, data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if note.Content, err = tmpl.Execute("content", t.Content, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := repo().CreateNote(&note); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCreated(w, media, note)
+}
+
+func findTemplate(w http.ResponseWriter, idOrName string) (models.Template, bool) {
+	t, err := repo().Template(idOrName)
+	if err != nil {
+		lookupError(w, err, "Template")
+		return t, false
+	}
+	return t, true
+}
+
+// templateWriteError answers a failed template write; a name taken by
+// another template is a conflict.
+func templateWriteError(w http.ResponseWriter, err error, t models.Template) {
+	if errors.Is(err, repository.ErrDuplicate) {
+		http.Error(w, "A template named "+strconv.Quote(t.Name)+" exists already", http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func validateTemplate(t models.Template) error {
+	if err := tmpl.Validate("title", t.Title); err != nil {
+		return err
+	}
+	return tmpl.Validate("content", t.Content)
+}
+
+func withPrompts(t *models.Template) {
+	t.Prompts = tmpl.Prompts(t.Title, t.Content)
+}
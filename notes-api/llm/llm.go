@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEndpoint = "http://localhost:11434/api/generate"
+	defaultModel    = "llama3.2"
+)
+
+// Endpoint and Model point at the local Ollama server. They can be overridden
+// with the OLLAMA_ENDPOINT and OLLAMA_MODEL environment variables.
+var (
+	Endpoint = envOr("OLLAMA_ENDPOINT", defaultEndpoint)
+	Model    = envOr("OLLAMA_MODEL", defaultModel)
+	Timeout  = 60 * time.Second
+)
+
+type requestBody struct {
+	Prompt string `json:"prompt"`
+	Model  string `json:"model,omitempty"`
+	Stream bool   `json:"stream"`
+}
+
+type responseBody struct {
+	Response string `json:"response"`
+}
+
+// Generate sends a prompt to Ollama and returns the full, non-streamed response.
+func Generate(ctx context.Context, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	reqBytes, err := json.Marshal(requestBody{Prompt: prompt, Model: Model})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, Endpoint, bytes.NewReader(reqBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama returned non-200 status %d: %s", resp.StatusCode, body)
+	}
+
+	var out responseBody
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return strings.TrimSpace(out.Response), nil
+}
+
+// Summarize returns a short summary of a note's content.
+func Summarize(ctx context.Context, title, content string) (string, error) {
+	prompt := fmt.Sprintf(`Summarize the following note in at most two sentences.
+Return only the summary, no other text.
+
+Title: %s
+
+%s`, title, content)
+	return Generate(ctx, prompt)
+}
+
+// Title suggests a short title for a note's content.
+func Title(ctx context.Context, content string) (string, error) {
+	prompt := fmt.Sprintf(`Write a short title (at most eight words) for the following note.
+Return only the title, without quotes or other text.
+
+%s`, content)
+	title, err := Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(title, "\"'"), nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
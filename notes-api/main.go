@@ -3,8 +3,10 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 
 	"notes-api/db"
+	"notes-api/handlers"
 	"notes-api/routes"
 )
 
@@ -14,6 +16,9 @@ func main() {
 		log.Fatal("Failed to initialize database:", err)
 	}
 
+	// Opt in to LLM-generated titles for untitled notes
+	handlers.AutoTitle = os.Getenv("NOTES_AUTO_TITLE") == "true"
+
 	// Setup routes
 	r := routes.SetupRouter()
 
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Change records that a note was created, updated or deleted. Seq increases
+// monotonically and serves as the sync change token; delete changes are the
+// tombstones of removed notes.
+type Change struct {
+	Seq       uint64    `gorm:"primaryKey;autoIncrement" json:"seq"`
+	NoteID    string    `gorm:"index" json:"note_id"`
+	Op        string    `json:"op"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// The hooks run inside the transaction of the write, so every stored change
+// to a note is recorded atomically with it.
+
+func (n *Note) AfterCreate(tx *gorm.DB) error {
+	return recordChange(tx, n.ID, ChangeCreate)
+}
+
+func (n *Note) AfterUpdate(tx *gorm.DB) error {
+	return recordChange(tx, n.ID, ChangeUpdate)
+}
+
+// BeforeDelete records the tombstone up front because the row count is not
+// available to after-delete hooks; deleting a missing note records nothing.
+func (n *Note) BeforeDelete(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Session(&gorm.Session{NewDB: true}).Model(&Note{}).Where("id = ?", n.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return recordChange(tx, n.ID, ChangeDelete)
+}
+
+func recordChange(tx *gorm.DB, noteID, op string) error {
+	if noteID == "" {
+		return nil
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&Change{NoteID: noteID, Op: op}).Error
+}
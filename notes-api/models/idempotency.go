@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey is a client's Idempotency-Key with the request it was first
+// sent with and the response to replay on retries. Status is 0 while the
+// first request is still being handled.
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey"`
+	RequestHash string
+	Status      int
+	ContentType string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time `gorm:"index;autoCreateTime"`
+}
@@ -7,7 +7,21 @@ import (
 type Note struct {
 	ID        string    `gorm:"primaryKey" json:"id"`
 	Title     string    `json:"title"`
+	Slug      string    `gorm:"index:idx_notes_slug,unique,where:slug <> ''" json:"slug,omitempty"`
 	Content   string    `json:"content"`
+	Summary   string    `json:"summary"`
+	Day       string    `gorm:"index:idx_notes_daily,unique,where:day <> ''" json:"day,omitempty"`
+	Pinned    bool      `gorm:"default:false" json:"pinned"`
+	Archived  bool      `gorm:"index;default:false" json:"archived"`
+	Favorite  bool      `gorm:"default:false" json:"favorite"`
+	Encrypted bool      `gorm:"default:false" json:"encrypted"`
+	Tags      []string  `gorm:"serializer:json" json:"tags"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// Indexable reports whether the server may read the note's content, e.g. to
+// summarize it. Encrypted notes only hold client-side ciphertext.
+func (n Note) Indexable() bool {
+	return !n.Encrypted
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+type Template struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex" json:"name"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Prompts   []string  `gorm:"-" json:"prompts"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
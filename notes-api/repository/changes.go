@@ -0,0 +1,17 @@
+package repository
+
+import "notes-api/models"
+
+// Changes lists the changes after a change token, oldest first.
+func (r Repo) Changes(since uint64) ([]models.Change, error) {
+	var changes []models.Change
+	err := r.db.Where("seq > ?", since).Order("seq").Find(&changes).Error
+	return changes, err
+}
+
+// LastChange returns the token of the latest change, or 0 if there is none.
+func (r Repo) LastChange() (uint64, error) {
+	var seq uint64
+	err := r.db.Model(&models.Change{}).Select("COALESCE(MAX(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
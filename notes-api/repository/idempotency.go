@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"time"
+
+	"notes-api/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKey returns a stored idempotency key.
+func (r Repo) IdempotencyKey(key string) (models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	err := first(r.db.Where("key = ?", key), &k)
+	return k, err
+}
+
+// ReserveIdempotencyKey stores a new key and reports whether it was new. A
+// key that is already stored is left as it is.
+func (r Repo) ReserveIdempotencyKey(k *models.IdempotencyKey) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(k)
+	return res.RowsAffected == 1, res.Error
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (r Repo) CompleteIdempotencyKey(k *models.IdempotencyKey) error {
+	return r.db.Model(&models.IdempotencyKey{}).Where("key = ?", k.Key).Updates(map[string]any{
+		"status":       k.Status,
+		"content_type": k.ContentType,
+		"location":     k.Location,
+		"body":         k.Body,
+	}).Error
+}
+
+// DeleteIdempotencyKey deletes a key, so the request it guarded can run again.
+func (r Repo) DeleteIdempotencyKey(key string) error {
+	return r.db.Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// ExpireIdempotencyKeys deletes the keys stored before a time.
+func (r Repo) ExpireIdempotencyKeys(before time.Time) error {
+	return r.db.Where("created_at < ?", before).Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"notes-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Flag is a boolean column of a note that can be toggled.
+type Flag string
+
+const (
+	Pinned   Flag = "pinned"
+	Archived Flag = "archived"
+	Favorite Flag = "favorite"
+)
+
+// NoteFilter selects the notes listed by Notes.
+type NoteFilter struct {
+	// UpdatedSince, if not zero, selects every note changed after it,
+	// archived or not, and the other fields are ignored.
+	UpdatedSince time.Time
+	Archived     bool
+	FavoriteOnly bool
+}
+
+// Note returns the note with the given ID.
+func (r Repo) Note(id string) (models.Note, error) {
+	var note models.Note
+	err := first(r.db.Where("id = ?", id), &note)
+	return note, err
+}
+
+// NoteByIDOrSlug returns the note with the given ID or, failing that, the
+// given slug.
+func (r Repo) NoteByIDOrSlug(key string) (models.Note, error) {
+	note, err := r.Note(key)
+	if !errors.Is(err, ErrNotFound) {
+		return note, err
+	}
+	err = first(r.db.Where("slug = ?", key), &note)
+	return note, err
+}
+
+// DailyNote returns the daily note of a day formatted as yyyy-mm-dd.
+func (r Repo) DailyNote(day string) (models.Note, error) {
+	var note models.Note
+	err := first(r.db.Where("day = ?", day), &note)
+	return note, err
+}
+
+// Notes lists notes with pinned notes first, then oldest first.
+func (r Repo) Notes(f NoteFilter) ([]models.Note, error) {
+	q := r.db
+	if !f.UpdatedSince.IsZero() {
+		q = q.Where("updated_at > ?", f.UpdatedSince.Local())
+	} else {
+		q = q.Where("archived = ?", f.Archived)
+		if f.FavoriteOnly {
+			q = q.Where("favorite = ?", true)
+		}
+	}
+	notes := []models.Note{}
+	err := q.Order("pinned DESC").Order("created_at").Find(&notes).Error
+	return notes, err
+}
+
+// NotesByID returns the notes with the given IDs that exist, in no
+// particular order.
+func (r Repo) NotesByID(ids []string) ([]models.Note, error) {
+	notes := []models.Note{}
+	err := r.db.Where("id IN ?", ids).Find(&notes).Error
+	return notes, err
+}
+
+// SearchNotes returns up to limit unencrypted, unarchived notes whose title
+// or content contains every word, case-insensitively, most recently updated
+// first.
+func (r Repo) SearchNotes(words []string, limit int) ([]models.Note, error) {
+	q := r.db.Where("encrypted = ? AND archived = ?", false, false)
+	for _, word := range words {
+		pattern := "%" + escapeLike(strings.ToLower(word)) + "%"
+		q = q.Where("(LOWER(title) LIKE ? ESCAPE '\\' OR LOWER(content) LIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+	notes := []models.Note{}
+	err := q.Order("updated_at DESC").Limit(limit).Find(&notes).Error
+	return notes, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DailyDays lists the days of a month, formatted as yyyy-mm, that have a
+// non-empty daily note.
+func (r Repo) DailyDays(month string) ([]string, error) {
+	days := []string{}
+	err := r.db.Model(&models.Note{}).
+		Where("day LIKE ? AND content <> ''", escapeLike(month)+"-%").
+		Order("day").
+		Pluck("day", &days).Error
+	return days, err
+}
+
+// CreateNote stores a new note, giving it a slug if it has a title.
+func (r Repo) CreateNote(note *models.Note) error {
+	return r.writeWithSlug(note, func() error {
+		return r.db.Create(note).Error
+	})
+}
+
+// CreateDailyNote stores note as the daily note of its day, unless the day
+// has one already because another request created it concurrently. Either
+// way it returns the day's note.
+func (r Repo) CreateDailyNote(note models.Note) (models.Note, error) {
+	err := r.CreateNote(&note)
+	if err == nil {
+		return note, nil
+	}
+	// The unique index on day refused a second daily note.
+	if existing, lookupErr := r.DailyNote(note.Day); lookupErr == nil {
+		return existing, nil
+	}
+	return note, err
+}
+
+// SaveNote writes every field of a note, zero values included.
+func (r Repo) SaveNote(note *models.Note) error {
+	return r.writeWithSlug(note, func() error {
+		return r.db.Save(note).Error
+	})
+}
+
+// UpdateNote writes the non-zero fields of a note.
+func (r Repo) UpdateNote(note *models.Note) error {
+	return r.writeWithSlug(note, func() error {
+		return r.db.Where("id = ?", note.ID).Updates(note).Error
+	})
+}
+
+// SetTags writes the tags of a note.
+func (r Repo) SetTags(note *models.Note) error {
+	return r.db.Model(note).Select("tags").Updates(note).Error
+}
+
+// SetSummary writes the summary of a note.
+func (r Repo) SetSummary(note *models.Note) error {
+	return r.db.Model(note).Update("summary", note.Summary).Error
+}
+
+// ToggleNote flips a flag of a note and returns the note.
+func (r Repo) ToggleNote(id string, flag Flag) (models.Note, error) {
+	switch flag {
+	case Pinned, Archived, Favorite:
+	default:
+		return models.Note{}, errors.New("unknown flag " + string(flag))
+	}
+	note, err := r.Note(id)
+	if err != nil {
+		return note, err
+	}
+	if err := r.db.Model(&note).Update(string(flag), gorm.Expr("NOT "+string(flag))).Error; err != nil {
+		return note, err
+	}
+	return r.Note(id)
+}
+
+// DeleteNote deletes a note. ErrNotFound means there was nothing to delete.
+func (r Repo) DeleteNote(id string) error {
+	res := r.db.Where("id = ?", id).Delete(&models.Note{ID: id})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
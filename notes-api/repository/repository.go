@@ -0,0 +1,52 @@
+// Package repository is the data access layer of notes-api. Handlers look
+// records up through it rather than building queries, so every lookup is
+// parameterized and typed.
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when no record matches a lookup.
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned when a write would give a record a value that
+// must be unique, such as a template name, that another record has.
+var ErrDuplicate = errors.New("duplicate record")
+
+// Repo reads and writes records through a database handle, which may be a
+// transaction.
+type Repo struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) Repo {
+	return Repo{db: db}
+}
+
+// Transaction runs fn in a transaction, which is committed if fn returns
+// nil and rolled back otherwise.
+func (r Repo) Transaction(fn func(tx Repo) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
+// duplicate reports a unique constraint violation as ErrDuplicate.
+func duplicate(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// first loads the first record of q into v.
+func first(q *gorm.DB, v any) error {
+	err := q.First(v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
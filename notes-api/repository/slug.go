@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"notes-api/models"
+
+	"gorm.io/gorm"
+)
+
+// maxSlugLen bounds the length of slugs in bytes, before the suffix that
+// makes them unique.
+const maxSlugLen = 60
+
+// Slugify turns a title into its URL form: lower case letters and digits
+// separated by single dashes, as in "weekly-review-2025". Long slugs are
+// cut at a word. Titles without letters or digits have no slug.
+func Slugify(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	slug := ""
+	for _, w := range words {
+		next := w
+		if slug != "" {
+			next = slug + "-" + w
+		}
+		if len(next) > maxSlugLen {
+			if slug == "" {
+				// A single long word is cut at a rune.
+				for i := range w {
+					if i > maxSlugLen {
+						break
+					}
+					slug = w[:i]
+				}
+			}
+			break
+		}
+		slug = next
+	}
+	return slug
+}
+
+// assignSlug gives a titled note without a slug one that no other note
+// has, numbering repeated titles as in "standup-2". A slug is kept when the
+// title changes, so links to the note stay valid. Encrypted notes have no
+// slug: one made from ciphertext is useless and gives its shape away.
+func (r Repo) assignSlug(note *models.Note) error {
+	if note.Encrypted {
+		note.Slug = ""
+		return nil
+	}
+	if note.Slug != "" {
+		return nil
+	}
+	base := Slugify(note.Title)
+	if base == "" {
+		return nil
+	}
+	slug := base
+	for i := 2; ; i++ {
+		var count int64
+		err := r.db.Model(&models.Note{}).Where("slug = ? AND id <> ?", slug, note.ID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			note.Slug = slug
+			return nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// writeWithSlug assigns note a slug and stores it with write. A concurrent
+// request can take the slug between the check and the write, in which case
+// the unique index refuses it and the write is retried with the next free
+// slug.
+func (r Repo) writeWithSlug(note *models.Note, write func() error) error {
+	assigned := note.Slug == ""
+	for {
+		if err := r.assignSlug(note); err != nil {
+			return err
+		}
+		err := write()
+		if !assigned || note.Slug == "" || !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return err
+		}
+		// Other unique columns, such as a daily note's day, are the
+		// caller's to handle.
+		var count int64
+		if r.db.Model(&models.Note{}).Where("slug = ? AND id <> ?", note.Slug, note.ID).Count(&count).Error != nil || count == 0 {
+			return err
+		}
+		note.Slug = ""
+	}
+}
+
+// BackfillSlugs gives slugs to titled notes stored before notes had them,
+// and takes them from encrypted notes, which used to get them too.
+func (r Repo) BackfillSlugs() error {
+	if err := r.db.Model(&models.Note{}).Where("encrypted = ? AND slug <> ''", true).UpdateColumn("slug", "").Error; err != nil {
+		return err
+	}
+	var notes []models.Note
+	if err := r.db.Where("(slug IS NULL OR slug = '') AND title <> '' AND encrypted = ?", false).Order("created_at").Find(&notes).Error; err != nil {
+		return err
+	}
+	for i := range notes {
+		if err := r.assignSlug(&notes[i]); err != nil {
+			return err
+		}
+		if err := r.db.Model(&notes[i]).UpdateColumn("slug", notes[i].Slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
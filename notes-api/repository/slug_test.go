@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"notes-api/models"
+)
+
+func TestSlugify(t *testing.T) {
+	for _, tc := range []struct{ title, want string }{
+		{"Groceries", "groceries"},
+		{"  Weekly review: March 2025! ", "weekly-review-march-2025"},
+		{"C++ & Go -- notes", "c-go-notes"},
+		{"Café crème", "café-crème"},
+		{"2025-03-03", "2025-03-03"},
+		{"!!!", ""},
+		{"", ""},
+		{"a very long title that keeps going well past the limit of sixty bytes", "a-very-long-title-that-keeps-going-well-past-the-limit-of"},
+		{strings.Repeat("é", 40), strings.Repeat("é", 30)},
+	} {
+		if got := Slugify(tc.title); got != tc.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestEncryptedNotesHaveNoSlug(t *testing.T) {
+	// Encrypted notes are settled before the database is asked for clashes.
+	for _, slug := range []string{"", "diary"} {
+		note := models.Note{Title: "enc:v2:c2VjcmV0", Slug: slug, Encrypted: true}
+		if err := (Repo{}).assignSlug(&note); err != nil {
+			t.Fatal(err)
+		}
+		if note.Slug != "" {
+			t.Errorf("encrypted note got slug %q", note.Slug)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import "notes-api/models"
+
+// Template returns the template with the given ID or name.
+func (r Repo) Template(idOrName string) (models.Template, error) {
+	var t models.Template
+	err := first(r.db.Where("id = ? OR name = ?", idOrName, idOrName), &t)
+	return t, err
+}
+
+// Templates lists templates by name.
+func (r Repo) Templates() ([]models.Template, error) {
+	templates := []models.Template{}
+	err := r.db.Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// CreateTemplate stores a new template. ErrDuplicate means another template
+// has its name.
+func (r Repo) CreateTemplate(t *models.Template) error {
+	return duplicate(r.db.Create(t).Error)
+}
+
+// SaveTemplate writes every field of a template, zero values included.
+// ErrDuplicate means another template has its name.
+func (r Repo) SaveTemplate(t *models.Template) error {
+	return duplicate(r.db.Save(t).Error)
+}
+
+// DeleteTemplate deletes a template. ErrNotFound means there was nothing to
+// delete.
+func (r Repo) DeleteTemplate(id string) error {
+	res := r.db.Where("id = ?", id).Delete(&models.Template{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package routes_test
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"notes-api/apitest"
+	"notes-api/models"
+)
+
+const writers = 20
+
+// parallel sends a request from each of n goroutines and returns the
+// responses in order. Requests that fail to be sent fail the test once all
+// are done, since only the test goroutine may stop the test.
+func parallel(t *testing.T, srv *apitest.Server, n int, req func(i int) (method, path string, body any)) []*apitest.Response {
+	t.Helper()
+	resps := make([]*apitest.Response, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			method, path, body := req(i)
+			resp, err := srv.Try(method, path, nil, body)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resps[i] = resp
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	return resps
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	srv := newServer(t)
+
+	resps := parallel(t, srv, writers, func(i int) (string, string, any) {
+		return http.MethodPost, "/notes", models.Note{Title: fmt.Sprintf("Note %d", i)}
+	})
+	ids := map[string]bool{}
+	for _, resp := range resps {
+		var n models.Note
+		resp.Expect(http.StatusCreated).Decode(&n)
+		ids[n.ID] = true
+	}
+	if len(ids) != writers {
+		t.Errorf("%d distinct IDs for %d creates", len(ids), writers)
+	}
+
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if len(notes) != 3+writers {
+		t.Errorf("%d notes, want %d", len(notes), 3+writers)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	srv := newServer(t)
+
+	resps := parallel(t, srv, writers, func(i int) (string, string, any) {
+		return http.MethodPut, "/notes/" + groceriesID, models.Note{Title: "Groceries", Content: fmt.Sprintf("version %d", i)}
+	})
+	sent := map[string]bool{}
+	for _, resp := range resps {
+		var n models.Note
+		resp.Expect(http.StatusOK).Decode(&n)
+		sent[n.Content] = true
+	}
+
+	// The last write wins whole: the stored note is one of the writes.
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	for _, n := range notes {
+		if n.ID == groceriesID && !sent[n.Content] {
+			t.Errorf("stored content %q was never sent", n.Content)
+		}
+	}
+
+	// Every update is in the change feed, after the creates of the four
+	// fixture notes.
+	var feed struct {
+		Token string `json:"token"`
+	}
+	srv.Do(http.MethodGet, "/sync", nil).Expect(http.StatusOK).Decode(&feed)
+	if want := fmt.Sprint(4 + writers); feed.Token != want {
+		t.Errorf("change token %s, want %s", feed.Token, want)
+	}
+}
+
+func TestConcurrentToggles(t *testing.T) {
+	srv := newServer(t)
+
+	// An even number of toggles leaves the flag as it was.
+	for _, resp := range parallel(t, srv, writers, func(int) (string, string, any) {
+		return http.MethodPost, "/notes/" + standupID + "/pin", nil
+	}) {
+		resp.Expect(http.StatusOK)
+	}
+
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if len(notes) == 0 || notes[0].ID != standupID || !notes[0].Pinned {
+		t.Errorf("standup note is no longer pinned first: %q", titles(notes))
+	}
+}
+
+func TestConcurrentDaily(t *testing.T) {
+	srv := newServer(t)
+
+	// The first requests of a day race to create its note; all get the same.
+	ids := map[string]bool{}
+	for _, resp := range parallel(t, srv, writers, func(int) (string, string, any) {
+		return http.MethodGet, "/daily/2025-04-01", nil
+	}) {
+		var n models.Note
+		resp.Expect(http.StatusOK).Decode(&n)
+		ids[n.ID] = true
+	}
+	if len(ids) != 1 {
+		t.Errorf("%d daily notes for one day", len(ids))
+	}
+}
+
+func TestConcurrentSameTitle(t *testing.T) {
+	srv := newServer(t)
+
+	resps := parallel(t, srv, writers, func(int) (string, string, any) {
+		return http.MethodPost, "/notes", models.Note{Title: "Retro"}
+	})
+	slugs := map[string]bool{}
+	for _, resp := range resps {
+		var n models.Note
+		resp.Expect(http.StatusCreated).Decode(&n)
+		slugs[n.Slug] = true
+	}
+	if len(slugs) != writers || !slugs["retro"] || !slugs[fmt.Sprintf("retro-%d", writers)] {
+		t.Errorf("slugs %v, want retro to retro-%d", slugs, writers)
+	}
+}
@@ -0,0 +1,112 @@
+package routes_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"notes-api/models"
+)
+
+func accept(media string) http.Header {
+	return http.Header{"Accept": {media}}
+}
+
+func TestCreatedLocation(t *testing.T) {
+	srv := newServer(t)
+
+	resp := srv.Do(http.MethodPost, "/notes", models.Note{Title: "Ideas"}).Expect(http.StatusCreated)
+	var created models.Note
+	resp.Decode(&created)
+	if loc := resp.Header.Get("Location"); loc != "/notes/"+created.ID {
+		t.Fatalf("Location %q, want /notes/%s", loc, created.ID)
+	}
+
+	var note models.Note
+	srv.Do(http.MethodGet, resp.Header.Get("Location"), nil).Expect(http.StatusOK).Decode(&note)
+	if note.ID != created.ID {
+		t.Errorf("Location leads to %+v", note)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	srv := newServer(t)
+
+	for _, path := range []string{"/notes", "/notes/groceries", "/search?q=milk", "/templates", "/calendar?month=2025-03", "/sync"} {
+		resp := srv.Do(http.MethodGet, path, nil).Expect(http.StatusOK)
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("GET %s: Content-Type %q", path, ct)
+		}
+	}
+	resp := srv.Do(http.MethodGet, "/notes/"+missingID, nil).Expect(http.StatusNotFound)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("error Content-Type %q", ct)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	srv := newServer(t)
+
+	resp := srv.Send(http.MethodGet, "/notes/groceries", accept("text/markdown"), nil).Expect(http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/markdown; charset=utf-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	want := `---
+id: 5b1f7c2e-1d4a-4c8e-9a57-0c3e2f1b6a01
+slug: groceries
+tags: [home]
+created_at: 2025-03-03T09:00:00Z
+updated_at: 2025-03-03T09:00:00Z
+---
+
+# Groceries
+
+Milk
+Eggs
+Bread
+`
+	if string(resp.Body) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", resp.Body, want)
+	}
+
+	// Lists are the notes one after the other.
+	resp = srv.Send(http.MethodGet, "/search?q=today", accept("text/markdown"), nil).Expect(http.StatusOK)
+	if body := string(resp.Body); !strings.HasPrefix(body, "---\n") || !strings.Contains(body, "\n# Standup\n") {
+		t.Errorf("search as Markdown:\n%s", body)
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	srv := newServer(t)
+
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/json", "application/json"},
+		{"text/markdown", "text/markdown; charset=utf-8"},
+		{"text/*", "text/markdown; charset=utf-8"},
+		{"text/markdown;q=0.5, application/json;q=0.9", "application/json"},
+		{"application/json;q=0.1, text/*;q=0.5", "text/markdown; charset=utf-8"},
+		{"text/html, */*;q=0.1", "application/json"},
+	} {
+		resp := srv.Send(http.MethodGet, "/notes", accept(tc.accept), nil).Expect(http.StatusOK)
+		if ct := resp.Header.Get("Content-Type"); ct != tc.want {
+			t.Errorf("Accept %q: Content-Type %q, want %q", tc.accept, ct, tc.want)
+		}
+	}
+
+	for _, media := range []string{"text/html", "application/xml", "application/json;q=0, text/markdown;q=0"} {
+		srv.Send(http.MethodGet, "/notes", accept(media), nil).Expect(http.StatusNotAcceptable)
+	}
+
+	// Unacceptable requests change nothing.
+	srv.Send(http.MethodPost, "/notes", accept("text/html"), models.Note{Title: "Ideas"}).Expect(http.StatusNotAcceptable)
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if len(notes) != 3 {
+		t.Errorf("%d notes after a refused create, want 3", len(notes))
+	}
+}
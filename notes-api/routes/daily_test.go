@@ -0,0 +1,32 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"notes-api/models"
+)
+
+func TestUpdateDaily(t *testing.T) {
+	srv := newServer(t)
+	const path = "/daily/2025-04-01"
+
+	var note models.Note
+	srv.Do(http.MethodPut, path, map[string]any{"content": "Ran 5k", "summary": "Ran"}).Expect(http.StatusOK).Decode(&note)
+	if note.Day != "2025-04-01" || note.Content != "Ran 5k" {
+		t.Fatalf("got %+v", note)
+	}
+
+	srv.Do(http.MethodPut, path, map[string]any{"content": ""}).Expect(http.StatusOK)
+	srv.Do(http.MethodGet, path, nil).Expect(http.StatusOK).Decode(&note)
+	if note.Content != "" {
+		t.Errorf("content %q after clearing it", note.Content)
+	}
+
+	// A summary would leak the content of an encrypted note.
+	srv.Do(http.MethodPut, path, map[string]any{"content": "c2VjcmV0", "summary": "Ran", "encrypted": true}).Expect(http.StatusOK)
+	srv.Do(http.MethodGet, path, nil).Expect(http.StatusOK).Decode(&note)
+	if !note.Encrypted || note.Summary != "" {
+		t.Errorf("encrypted daily note %+v", note)
+	}
+}
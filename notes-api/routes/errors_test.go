@@ -0,0 +1,85 @@
+package routes_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"notes-api/models"
+)
+
+func TestNotFound(t *testing.T) {
+	srv := newServer(t)
+
+	for _, tc := range []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, "/notes/" + missingID, nil},
+		{http.MethodPut, "/notes/" + missingID, `{"title":"x"}`},
+		{http.MethodDelete, "/notes/" + missingID, nil},
+		{http.MethodPost, "/notes/" + missingID + "/pin", nil},
+		{http.MethodPost, "/notes/" + missingID + "/archive", nil},
+		{http.MethodPost, "/notes/" + missingID + "/favorite", nil},
+		{http.MethodPost, "/notes/" + missingID + "/summarize", nil},
+		{http.MethodPost, "/notes/" + missingID + "/tags", `{"add":["x"]}`},
+		{http.MethodGet, "/templates/" + missingID, nil},
+		{http.MethodPut, "/templates/" + missingID, `{"name":"x"}`},
+		{http.MethodDelete, "/templates/" + missingID, nil},
+		{http.MethodPost, "/notes?template=missing", `{}`},
+		{http.MethodGet, "/nowhere", nil},
+	} {
+		if resp := srv.Do(tc.method, tc.path, tc.body); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s %s: status %d, want 404", tc.method, tc.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestMalformedBodies(t *testing.T) {
+	srv := newServer(t)
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/notes", `{"title":`},
+		{http.MethodPost, "/notes", `["not", "a", "note"]`},
+		{http.MethodPut, "/notes/" + groceriesID, `{"pinned":"yes"}`},
+		{http.MethodPost, "/notes/" + groceriesID + "/tags", `{"add":"home"}`},
+		{http.MethodPut, "/daily/2025-03-03", `nope`},
+		{http.MethodPost, "/templates", `{"name":`},
+		{http.MethodPost, "/templates", `{"name":"broken","content":"{{.Date"}`},
+		{http.MethodPost, "/notes?template=meeting", `{"vars":[]}`},
+		{http.MethodPost, "/sync", `{"changes":{}}`},
+	} {
+		if resp := srv.Do(tc.method, tc.path, tc.body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s %s: status %d, want 400", tc.method, tc.path, tc.body, resp.StatusCode)
+		}
+	}
+
+	// Rejected bodies leave the notes alone. The daily note is created on
+	// first access, before its body is read.
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if got, want := titles(notes), []string{"Standup", "Groceries", "Diary", "2025-03-03"}; !slices.Equal(got, want) {
+		t.Errorf("notes = %q, want %q", got, want)
+	}
+	if notes[1].Pinned || len(notes[1].Tags) != 1 {
+		t.Errorf("groceries changed: %+v", notes[1])
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	srv := newServer(t)
+
+	for _, path := range []string{
+		"/notes?updated_since=yesterday",
+		"/search?q=",
+		"/daily/03-03-2025",
+		"/calendar?month=March",
+		"/sync?since=-1",
+	} {
+		if resp := srv.Do(http.MethodGet, path, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, resp.StatusCode)
+		}
+	}
+}
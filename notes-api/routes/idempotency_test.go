@@ -0,0 +1,159 @@
+package routes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notes-api/apitest"
+	"notes-api/handlers"
+	"notes-api/models"
+)
+
+func idempotencyKey(key string) http.Header {
+	return http.Header{"Idempotency-Key": {key}}
+}
+
+func noteCount(srv *apitest.Server) int {
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	return len(notes)
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	srv := newServer(t)
+	note := models.Note{Title: "Ideas", Content: "Tabs"}
+
+	first := srv.Send(http.MethodPost, "/notes", idempotencyKey("ideas-1"), note).Expect(http.StatusCreated)
+	retry := srv.Send(http.MethodPost, "/notes", idempotencyKey("ideas-1"), note).Expect(http.StatusCreated)
+	if string(retry.Body) != string(first.Body) || retry.Header.Get("Location") != first.Header.Get("Location") {
+		t.Errorf("retry answered %s at %q, first %s at %q",
+			retry.Body, retry.Header.Get("Location"), first.Body, first.Header.Get("Location"))
+	}
+	if first.Header.Get("Idempotent-Replayed") != "" || retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("only the retry should be marked as replayed")
+	}
+	if ct := retry.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("replayed Content-Type %q", ct)
+	}
+	if n := noteCount(srv); n != 4 {
+		t.Errorf("%d notes, want 4", n)
+	}
+
+	// Another key, or none, is another note.
+	srv.Send(http.MethodPost, "/notes", idempotencyKey("ideas-2"), note).Expect(http.StatusCreated)
+	srv.Do(http.MethodPost, "/notes", note).Expect(http.StatusCreated)
+	if n := noteCount(srv); n != 6 {
+		t.Errorf("%d notes, want 6", n)
+	}
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	srv := newServer(t)
+
+	srv.Send(http.MethodPost, "/notes", idempotencyKey("k"), models.Note{Title: "Ideas"}).Expect(http.StatusCreated)
+	srv.Send(http.MethodPost, "/notes", idempotencyKey("k"), models.Note{Title: "Other ideas"}).Expect(http.StatusUnprocessableEntity)
+	srv.Send(http.MethodPost, "/notes?template=meeting", idempotencyKey("k"), models.Note{Title: "Ideas"}).Expect(http.StatusUnprocessableEntity)
+	if n := noteCount(srv); n != 4 {
+		t.Errorf("%d notes, want 4", n)
+	}
+
+	// The stored response is JSON, so a retry asking for Markdown is
+	// another request.
+	markdown := idempotencyKey("k")
+	markdown.Set("Accept", "text/markdown")
+	srv.Send(http.MethodPost, "/notes", markdown, models.Note{Title: "Ideas"}).Expect(http.StatusUnprocessableEntity)
+
+	srv.Send(http.MethodPost, "/notes", idempotencyKey(strings.Repeat("k", 256)), models.Note{Title: "Ideas"}).Expect(http.StatusBadRequest)
+}
+
+func TestIdempotentFailures(t *testing.T) {
+	srv := newServer(t)
+
+	// Failed requests are not stored, so the key can be used again.
+	header := idempotencyKey("k")
+	header.Set("Accept", "text/html")
+	srv.Send(http.MethodPost, "/notes", header, models.Note{Title: "Ideas"}).Expect(http.StatusNotAcceptable)
+	srv.Send(http.MethodPost, "/notes", idempotencyKey("k"), models.Note{Title: "Ideas"}).Expect(http.StatusCreated)
+	if n := noteCount(srv); n != 4 {
+		t.Errorf("%d notes, want 4", n)
+	}
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	srv := newServer(t)
+	note := models.Note{Title: "Ideas"}
+	body, _ := json.Marshal(note)
+
+	// A panicking handler releases the key instead of leaving the request
+	// in progress until the key expires.
+	h := handlers.Idempotent(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("recovered %v, want the handler's panic", v)
+			}
+		}()
+		r := httptest.NewRequest(http.MethodPost, "/notes", bytes.NewReader(body))
+		r.Header.Set("Idempotency-Key", "k")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+
+	srv.Send(http.MethodPost, "/notes", idempotencyKey("k"), note).Expect(http.StatusCreated)
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	srv := newServer(t)
+	defer func(ttl time.Duration) { handlers.IdempotencyTTL = ttl }(handlers.IdempotencyTTL)
+	handlers.IdempotencyTTL = 0
+
+	for range 2 {
+		srv.Send(http.MethodPost, "/notes", idempotencyKey("k"), models.Note{Title: "Ideas"}).Expect(http.StatusCreated)
+	}
+	if n := noteCount(srv); n != 5 {
+		t.Errorf("%d notes, want 5", n)
+	}
+}
+
+func TestConcurrentIdempotentCreates(t *testing.T) {
+	srv := newServer(t)
+
+	// Retries racing the first request are told to wait; none creates a
+	// second note.
+	statuses := make(chan int, writers)
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := srv.Try(http.MethodPost, "/notes", idempotencyKey("k"), models.Note{Title: "Ideas"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for status := range statuses {
+		if status != http.StatusCreated && status != http.StatusConflict {
+			t.Errorf("status %d", status)
+		}
+	}
+	if n := noteCount(srv); n != 4 {
+		t.Errorf("%d notes, want 4", n)
+	}
+}
@@ -0,0 +1,198 @@
+package routes_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"notes-api/apitest"
+	"notes-api/models"
+)
+
+const (
+	groceriesID = "5b1f7c2e-1d4a-4c8e-9a57-0c3e2f1b6a01"
+	standupID   = "5b1f7c2e-1d4a-4c8e-9a57-0c3e2f1b6a02"
+	archivedID  = "5b1f7c2e-1d4a-4c8e-9a57-0c3e2f1b6a03"
+	diaryID     = "5b1f7c2e-1d4a-4c8e-9a57-0c3e2f1b6a04"
+	missingID   = "00000000-0000-0000-0000-000000000000"
+)
+
+func newServer(t *testing.T) *apitest.Server {
+	srv := apitest.New(t)
+	srv.Load("testdata/notes.json")
+	return srv
+}
+
+func titles(notes []models.Note) []string {
+	var t []string
+	for _, n := range notes {
+		t = append(t, n.Title)
+	}
+	return t
+}
+
+func TestNoteCRUD(t *testing.T) {
+	srv := newServer(t)
+
+	var created models.Note
+	srv.Do(http.MethodPost, "/notes", models.Note{Title: "Ideas", Content: "Tabs"}).Expect(http.StatusCreated).Decode(&created)
+	if created.ID == "" || created.Title != "Ideas" || created.CreatedAt.IsZero() {
+		t.Fatalf("created %+v", created)
+	}
+
+	var updated models.Note
+	srv.Do(http.MethodPut, "/notes/"+created.ID, models.Note{Title: "Ideas", Content: "Tabs and splits"}).
+		Expect(http.StatusOK).Decode(&updated)
+	if updated.Content != "Tabs and splits" || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("updated %+v", updated)
+	}
+
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if got, want := titles(notes), []string{"Standup", "Groceries", "Diary", "Ideas"}; !slices.Equal(got, want) {
+		t.Errorf("notes = %q, want %q", got, want)
+	}
+
+	if resp := srv.Do(http.MethodDelete, "/notes/"+created.ID, nil).Expect(http.StatusNoContent); len(resp.Body) != 0 {
+		t.Errorf("delete answered %q", resp.Body)
+	}
+	srv.Do(http.MethodDelete, "/notes/"+created.ID, nil).Expect(http.StatusNotFound)
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if len(notes) != 3 {
+		t.Errorf("%d notes after delete, want 3", len(notes))
+	}
+}
+
+func TestGetNote(t *testing.T) {
+	srv := newServer(t)
+
+	for _, key := range []string{groceriesID, "groceries"} {
+		var note models.Note
+		srv.Do(http.MethodGet, "/notes/"+key, nil).Expect(http.StatusOK).Decode(&note)
+		if note.ID != groceriesID || note.Slug != "groceries" {
+			t.Errorf("GET /notes/%s = %+v", key, note)
+		}
+	}
+
+	// Lookups are parameterized, so SQL in the key matches nothing.
+	for _, key := range []string{missingID, "1%20OR%201=1", "id%20%3D%20id", "groceries'--"} {
+		srv.Do(http.MethodGet, "/notes/"+key, nil).Expect(http.StatusNotFound)
+	}
+}
+
+func TestSlugs(t *testing.T) {
+	srv := newServer(t)
+
+	var first, second models.Note
+	srv.Do(http.MethodPost, "/notes", models.Note{Title: "Weekly review: March 2025!"}).Expect(http.StatusCreated).Decode(&first)
+	srv.Do(http.MethodPost, "/notes", models.Note{Title: "Weekly review — March 2025", Slug: "stolen"}).Expect(http.StatusCreated).Decode(&second)
+	if first.Slug != "weekly-review-march-2025" || second.Slug != "weekly-review-march-2025-2" {
+		t.Errorf("slugs %q and %q", first.Slug, second.Slug)
+	}
+
+	// Renaming keeps the slug, so links stay valid.
+	var renamed models.Note
+	srv.Do(http.MethodPut, "/notes/"+first.ID, models.Note{Title: "Review", Slug: "review"}).Expect(http.StatusOK).Decode(&renamed)
+	if renamed.Slug != first.Slug {
+		t.Errorf("slug changed to %q", renamed.Slug)
+	}
+	var note models.Note
+	srv.Do(http.MethodGet, "/notes/weekly-review-march-2025", nil).Expect(http.StatusOK).Decode(&note)
+	if note.ID != first.ID || note.Title != "Review" {
+		t.Errorf("got %+v", note)
+	}
+
+	// Untitled notes have no slug until they get a title.
+	var untitled models.Note
+	srv.Do(http.MethodPost, "/notes", models.Note{Content: "…"}).Expect(http.StatusCreated).Decode(&untitled)
+	if untitled.Slug != "" {
+		t.Errorf("untitled note has slug %q", untitled.Slug)
+	}
+	srv.Do(http.MethodPut, "/notes/"+untitled.ID, models.Note{Title: "Groceries"}).Expect(http.StatusOK).Decode(&untitled)
+	if untitled.Slug != "groceries-2" {
+		t.Errorf("slug %q, want groceries-2", untitled.Slug)
+	}
+
+	// Encrypted notes lose their slug, which could give their title away.
+	var created, updated models.Note
+	srv.Do(http.MethodPost, "/notes", models.Note{Title: "enc:v2:c2VjcmV0", Encrypted: true}).Expect(http.StatusCreated).Decode(&created)
+	srv.Do(http.MethodPut, "/notes/"+untitled.ID, models.Note{Title: "enc:v2:Z3JvY2VyaWVz", Encrypted: true}).Expect(http.StatusOK).Decode(&updated)
+	if created.Slug != "" || updated.Slug != "" {
+		t.Errorf("encrypted notes have slugs %q and %q", created.Slug, updated.Slug)
+	}
+	srv.Do(http.MethodGet, "/notes/groceries-2", nil).Expect(http.StatusNotFound)
+}
+
+func TestListFilters(t *testing.T) {
+	srv := newServer(t)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Standup", "Groceries", "Diary"}},
+		{"?archived=true", []string{"Old ideas"}},
+		{"?favorite=true", []string{"Diary"}},
+		{"?updated_since=2025-03-03T09:07:00Z", []string{"Old ideas", "Diary"}},
+	} {
+		var notes []models.Note
+		srv.Do(http.MethodGet, "/notes"+tc.query, nil).Expect(http.StatusOK).Decode(&notes)
+		if got := titles(notes); !slices.Equal(got, tc.want) {
+			t.Errorf("GET /notes%s = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestFlagsAndTags(t *testing.T) {
+	srv := newServer(t)
+
+	var note models.Note
+	srv.Do(http.MethodPost, "/notes/"+groceriesID+"/pin", nil).Expect(http.StatusOK).Decode(&note)
+	if !note.Pinned {
+		t.Error("pin did not pin the note")
+	}
+	srv.Do(http.MethodPost, "/notes/"+groceriesID+"/favorite", nil).Expect(http.StatusOK).Decode(&note)
+	if !note.Favorite || !note.Pinned {
+		t.Errorf("favorite: %+v", note)
+	}
+	srv.Do(http.MethodPost, "/notes/"+standupID+"/archive", nil).Expect(http.StatusOK).Decode(&note)
+	if !note.Archived {
+		t.Error("archive did not archive the note")
+	}
+
+	srv.Do(http.MethodPost, "/notes/"+groceriesID+"/tags", map[string][]string{
+		"add":    {" shopping ", "errands", ""},
+		"remove": {"home"},
+	}).Expect(http.StatusOK).Decode(&note)
+	if want := []string{"errands", "shopping"}; !slices.Equal(note.Tags, want) {
+		t.Errorf("tags = %q, want %q", note.Tags, want)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := newServer(t)
+
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/search?q=RELEASE+today", nil).Expect(http.StatusOK).Decode(&notes)
+	if got := titles(notes); !slices.Equal(got, []string{"Standup"}) {
+		t.Errorf("search = %q", got)
+	}
+	// Archived and encrypted notes are not searched.
+	srv.Do(http.MethodGet, "/search?q=notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if got := titles(notes); !slices.Equal(got, []string{"Standup"}) {
+		t.Errorf("search = %q", got)
+	}
+}
+
+func TestNoteFromTemplate(t *testing.T) {
+	srv := newServer(t)
+
+	var note models.Note
+	srv.Do(http.MethodPost, "/notes?template=meeting", map[string]any{
+		"title": "Kickoff",
+		"vars":  map[string]string{"Attendees": "Ana, Bo"},
+	}).Expect(http.StatusCreated).Decode(&note)
+	if note.Title != "Kickoff" || note.Content != "Attendees: Ana, Bo" {
+		t.Errorf("got %+v", note)
+	}
+}
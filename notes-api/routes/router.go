@@ -8,10 +8,26 @@ import (
 
 func SetupRouter() *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/notes", handlers.CreateNote)
+	r.With(handlers.Idempotent).Post("/notes", handlers.CreateNote)
 	r.Get("/notes", handlers.GetNotes)
+	r.Get("/search", handlers.SearchNotes)
 	r.Get("/notes/{id}", handlers.GetNote)
 	r.Put("/notes/{id}", handlers.UpdateNote)
 	r.Delete("/notes/{id}", handlers.DeleteNote)
+	r.Post("/notes/{id}/summarize", handlers.SummarizeNote)
+	r.Post("/notes/{id}/pin", handlers.TogglePinned)
+	r.Post("/notes/{id}/archive", handlers.ToggleArchived)
+	r.Post("/notes/{id}/favorite", handlers.ToggleFavorite)
+	r.Post("/notes/{id}/tags", handlers.TagNote)
+	r.Get("/daily/{date}", handlers.GetDaily)
+	r.Put("/daily/{date}", handlers.UpdateDaily)
+	r.Get("/calendar", handlers.GetCalendar)
+	r.Get("/sync", handlers.GetSync)
+	r.Post("/sync", handlers.PostSync)
+	r.Post("/templates", handlers.CreateTemplate)
+	r.Get("/templates", handlers.GetTemplates)
+	r.Get("/templates/{id}", handlers.GetTemplate)
+	r.Put("/templates/{id}", handlers.UpdateTemplate)
+	r.Delete("/templates/{id}", handlers.DeleteTemplate)
 	return r
 }
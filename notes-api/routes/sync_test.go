@@ -0,0 +1,115 @@
+package routes_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"notes-api/models"
+)
+
+type syncFeed struct {
+	Created []models.Note `json:"created"`
+	Updated []models.Note `json:"updated"`
+	Deleted []struct {
+		ID string `json:"id"`
+	} `json:"deleted"`
+	Token string `json:"token"`
+}
+
+type syncUpload struct {
+	Results []struct {
+		Op       string `json:"op"`
+		ID       string `json:"id"`
+		ClientID string `json:"client_id"`
+	} `json:"results"`
+	Token string `json:"token"`
+}
+
+func TestSyncTombstones(t *testing.T) {
+	srv := newServer(t)
+
+	var start syncFeed
+	srv.Do(http.MethodGet, "/sync", nil).Expect(http.StatusOK).Decode(&start)
+	if start.Token != "4" || len(start.Created) != 4 {
+		t.Fatalf("initial feed: token %s, %d created", start.Token, len(start.Created))
+	}
+
+	srv.Do(http.MethodDelete, "/notes/"+groceriesID, nil).Expect(http.StatusNoContent)
+	srv.Do(http.MethodPut, "/notes/"+standupID, models.Note{Title: "Standup", Content: "Done"}).Expect(http.StatusOK)
+
+	var feed syncFeed
+	srv.Do(http.MethodGet, "/sync?since="+start.Token, nil).Expect(http.StatusOK).Decode(&feed)
+	if feed.Token != "6" {
+		t.Errorf("token %s, want 6", feed.Token)
+	}
+	if len(feed.Deleted) != 1 || feed.Deleted[0].ID != groceriesID {
+		t.Errorf("deleted %+v", feed.Deleted)
+	}
+	if got := titles(feed.Updated); !slices.Equal(got, []string{"Standup"}) || len(feed.Created) != 0 {
+		t.Errorf("updated %q, created %q", got, titles(feed.Created))
+	}
+
+	// Deleting a missing note leaves no tombstone, and nothing new is
+	// reported after the latest token.
+	srv.Do(http.MethodDelete, "/notes/"+groceriesID, nil).Expect(http.StatusNotFound)
+	srv.Do(http.MethodGet, "/sync?since="+feed.Token, nil).Expect(http.StatusOK).Decode(&feed)
+	if feed.Token != "6" || len(feed.Deleted)+len(feed.Created)+len(feed.Updated) != 0 {
+		t.Errorf("feed after the last change %+v", feed)
+	}
+}
+
+func TestSyncUpload(t *testing.T) {
+	srv := newServer(t)
+
+	var resp syncUpload
+	srv.Do(http.MethodPost, "/sync", map[string]any{"changes": []map[string]any{
+		{"op": "create", "id": "local-1", "note": map[string]any{"title": "Ideas"}},
+		{"op": "update", "id": "local-1", "note": map[string]any{"title": "Ideas", "content": "Tabs"}},
+		{"op": "delete", "id": archivedID},
+	}}).Expect(http.StatusOK).Decode(&resp)
+	if resp.Token != "7" || len(resp.Results) != 3 || resp.Results[0].ClientID != "local-1" || resp.Results[1].ID != resp.Results[0].ID {
+		t.Fatalf("got %+v", resp)
+	}
+
+	var note models.Note
+	srv.Do(http.MethodGet, "/notes/"+resp.Results[0].ID, nil).Expect(http.StatusOK).Decode(&note)
+	if note.Content != "Tabs" {
+		t.Errorf("created note %+v", note)
+	}
+	srv.Do(http.MethodGet, "/notes/"+archivedID, nil).Expect(http.StatusNotFound)
+}
+
+func TestSyncUploadRollsBack(t *testing.T) {
+	srv := newServer(t)
+
+	for _, bad := range []map[string]any{
+		{"op": "update", "id": missingID, "note": map[string]any{"title": "x"}},
+		{"op": "update", "id": standupID, "note": "not a note"},
+		{"op": "move", "id": standupID},
+	} {
+		srv.Do(http.MethodPost, "/sync", map[string]any{"changes": []map[string]any{
+			{"op": "create", "id": "local-1", "note": map[string]any{"title": "Ideas"}},
+			{"op": "update", "id": groceriesID, "note": map[string]any{"title": "Groceries", "content": "Tea"}},
+			{"op": "delete", "id": diaryID},
+			bad,
+		}}).Expect(http.StatusConflict)
+	}
+
+	// None of the changes before the failing one were kept.
+	var feed syncFeed
+	srv.Do(http.MethodGet, "/sync?since=4", nil).Expect(http.StatusOK).Decode(&feed)
+	if feed.Token != "4" || len(feed.Created)+len(feed.Updated)+len(feed.Deleted) != 0 {
+		t.Errorf("changes after a failed batch: %+v", feed)
+	}
+	var notes []models.Note
+	srv.Do(http.MethodGet, "/notes", nil).Expect(http.StatusOK).Decode(&notes)
+	if got := titles(notes); !slices.Equal(got, []string{"Standup", "Groceries", "Diary"}) {
+		t.Errorf("notes = %q", got)
+	}
+	var groceries models.Note
+	srv.Do(http.MethodGet, "/notes/"+groceriesID, nil).Expect(http.StatusOK).Decode(&groceries)
+	if groceries.Content != "Milk\nEggs\nBread" {
+		t.Errorf("groceries content %q", groceries.Content)
+	}
+}
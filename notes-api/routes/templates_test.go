@@ -0,0 +1,47 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"notes-api/models"
+)
+
+func TestReplaceTemplate(t *testing.T) {
+	srv := newServer(t)
+
+	var created models.Template
+	srv.Do(http.MethodPost, "/templates", models.Template{Name: "retro", Title: "Retro", Content: "Went well:"}).
+		Expect(http.StatusCreated).Decode(&created)
+
+	// A replacement writes empty fields too.
+	var replaced models.Template
+	srv.Do(http.MethodPut, "/templates/"+created.ID, `{"name":"retro","title":"","content":""}`).
+		Expect(http.StatusOK).Decode(&replaced)
+	var got models.Template
+	srv.Do(http.MethodGet, "/templates/"+created.ID, nil).Expect(http.StatusOK).Decode(&got)
+	if got.Title != "" || got.Content != "" {
+		t.Errorf("replaced template = %+v, want empty title and content", got)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("created_at changed from %v to %v", created.CreatedAt, got.CreatedAt)
+	}
+
+	srv.Do(http.MethodPut, "/templates/"+created.ID, `{"name":""}`).Expect(http.StatusBadRequest)
+}
+
+func TestDuplicateTemplateName(t *testing.T) {
+	srv := newServer(t)
+
+	srv.Do(http.MethodPost, "/templates", models.Template{Name: "meeting"}).Expect(http.StatusConflict)
+
+	var created models.Template
+	srv.Do(http.MethodPost, "/templates", models.Template{Name: "retro"}).Expect(http.StatusCreated).Decode(&created)
+	srv.Do(http.MethodPut, "/templates/"+created.ID, models.Template{Name: "meeting"}).Expect(http.StatusConflict)
+
+	var got models.Template
+	srv.Do(http.MethodGet, "/templates/"+created.ID, nil).Expect(http.StatusOK).Decode(&got)
+	if got.Name != "retro" {
+		t.Errorf("name = %q after a rejected rename, want retro", got.Name)
+	}
+}
@@ -0,0 +1,174 @@
+// Package tmpl renders note templates written with text/template.
+//
+// Templates can reference {{.Date}}, {{.Time}}, {{.User}} and named values
+// requested from the user with {{prompt "Attendees"}}. {{date "Jan 2"}}
+// formats the current time with a custom layout.
+package tmpl
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Data is passed to a template when it is executed.
+type Data struct {
+	Date string
+	Time string
+	User string
+	Vars map[string]string
+	now  time.Time
+}
+
+// NewData returns template data for the given user and prompt answers.
+func NewData(user string, vars map[string]string, now time.Time) Data {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	return Data{
+		Date: now.Format("2006-01-02"),
+		Time: now.Format("15:04"),
+		User: user,
+		Vars: vars,
+		now:  now,
+	}
+}
+
+func funcs(data Data) template.FuncMap {
+	return template.FuncMap{
+		"prompt": func(name string) string {
+			return data.Vars[name]
+		},
+		"date": func(layout string) string {
+			return data.now.Format(layout)
+		},
+	}
+}
+
+func compile(name, text string, data Data) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs(data)).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Validate reports whether text is a valid template.
+func Validate(name, text string) error {
+	_, err := compile(name, text, NewData("", nil, time.Now()))
+	return err
+}
+
+// Execute renders text with the given data.
+func Execute(name, text string, data Data) (string, error) {
+	t, err := compile(name, text, data)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// Prompts returns the names of the values a set of templates asks the user
+// for, in order of first appearance. The templates are read rather than run,
+// so prompts inside conditions, loops and nested templates are found too.
+func Prompts(texts ...string) []string {
+	p := prompter{names: []string{}, seen: map[string]bool{}, visited: map[string]bool{}}
+	for _, text := range texts {
+		t, err := compile("prompts", text, NewData("", nil, time.Now()))
+		if err != nil {
+			continue
+		}
+		clear(p.visited)
+		p.tmpl = t
+		p.walk(t.Tree.Root)
+	}
+	return p.names
+}
+
+// prompter collects the names passed to prompt in a parsed template.
+type prompter struct {
+	tmpl  *template.Template
+	names []string
+	seen  map[string]bool
+	// visited holds the nested templates already walked, which may call
+	// each other.
+	visited map[string]bool
+}
+
+func (p *prompter) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			p.walk(child)
+		}
+	case *parse.ActionNode:
+		p.walk(n.Pipe)
+	case *parse.IfNode:
+		p.branch(&n.BranchNode)
+	case *parse.RangeNode:
+		p.branch(&n.BranchNode)
+	case *parse.WithNode:
+		p.branch(&n.BranchNode)
+	case *parse.TemplateNode:
+		p.walk(n.Pipe)
+		if nested := p.tmpl.Lookup(n.Name); nested != nil && nested.Tree != nil && !p.visited[n.Name] {
+			p.visited[n.Name] = true
+			p.walk(nested.Tree.Root)
+		}
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			if name, ok := promptName(n.Cmds[:i], cmd); ok && !p.seen[name] {
+				p.seen[name] = true
+				p.names = append(p.names, name)
+			}
+			p.walk(cmd)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			p.walk(arg)
+		}
+	case *parse.ChainNode:
+		p.walk(n.Node)
+	}
+}
+
+func (p *prompter) branch(n *parse.BranchNode) {
+	p.walk(n.Pipe)
+	p.walk(n.List)
+	p.walk(n.ElseList)
+}
+
+// promptName returns the name cmd prompts for, given either as its argument,
+// {{prompt "Name"}}, or piped in, {{"Name" | prompt}}.
+func promptName(before []*parse.CommandNode, cmd *parse.CommandNode) (string, bool) {
+	if fn, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || fn.Ident != "prompt" {
+		return "", false
+	}
+	if len(cmd.Args) > 1 {
+		name, ok := cmd.Args[1].(*parse.StringNode)
+		if !ok {
+			return "", false
+		}
+		return name.Text, true
+	}
+	if len(before) == 0 || len(before[len(before)-1].Args) != 1 {
+		return "", false
+	}
+	name, ok := before[len(before)-1].Args[0].(*parse.StringNode)
+	if !ok {
+		return "", false
+	}
+	return name.Text, true
+}
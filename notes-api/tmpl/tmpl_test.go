@@ -0,0 +1,63 @@
+package tmpl
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExecute(t *testing.T) {
+	now := time.Date(2025, 3, 4, 9, 30, 0, 0, time.UTC)
+	data := NewData("ada", map[string]string{"Attendees": "Bo, Cy"}, now)
+
+	for _, tc := range []struct{ text, want string }{
+		{"{{.Date}} {{.Time}} {{.User}}", "2025-03-04 09:30 ada"},
+		{`{{date "Jan 2"}}`, "Mar 4"},
+		{`With {{prompt "Attendees"}}`, "With Bo, Cy"},
+		{`[{{prompt "Agenda"}}]`, "[]"},
+		{`{{.Vars.Missing}}`, ""},
+	} {
+		got, err := Execute("content", tc.text, data)
+		if err != nil {
+			t.Errorf("Execute(%q): %v", tc.text, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Execute(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("content", `{{prompt "Topic"}} on {{.Date}}`); err != nil {
+		t.Error(err)
+	}
+	for _, text := range []string{"{{.Date", "{{nope}}", "{{if .User}}"} {
+		if err := Validate("content", text); err == nil {
+			t.Errorf("Validate(%q) accepted an invalid template", text)
+		}
+	}
+}
+
+func TestPrompts(t *testing.T) {
+	for _, tc := range []struct {
+		texts []string
+		want  []string
+	}{
+		{[]string{"{{.Date}}"}, []string{}},
+		{[]string{`{{prompt "Topic"}}`, `{{prompt "Attendees"}} on {{prompt "Topic"}}`}, []string{"Topic", "Attendees"}},
+		// Prompts are found in branches that an empty run would skip.
+		{[]string{`{{if .User}}{{prompt "Owner"}}{{else}}{{prompt "Team"}}{{end}}`}, []string{"Owner", "Team"}},
+		{[]string{`{{with .Vars.Room}}{{prompt "Floor"}}{{end}}`}, []string{"Floor"}},
+		{[]string{`{{range .Vars}}{{prompt "Item"}}{{else}}{{prompt "Empty"}}{{end}}`}, []string{"Item", "Empty"}},
+		{[]string{`{{if eq (prompt "Kind") "bug"}}{{prompt "Steps"}}{{end}}`}, []string{"Kind", "Steps"}},
+		{[]string{`{{$who := "Owner" | prompt}}{{$who}}`}, []string{"Owner"}},
+		{[]string{`{{define "sig"}}{{prompt "Signature"}}{{template "sig"}}{{end}}{{template "sig"}}`}, []string{"Signature"}},
+		// Invalid templates are skipped.
+		{[]string{"{{.Date", `{{prompt "Topic"}}`}, []string{"Topic"}},
+	} {
+		if got := Prompts(tc.texts...); !slices.Equal(got, tc.want) || got == nil {
+			t.Errorf("Prompts(%q) = %q, want %q", tc.texts, got, tc.want)
+		}
+	}
+}
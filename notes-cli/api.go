@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// All server calls run as tea.Cmds and report back with one of these
+// messages, so the UI never blocks on the network.
+
+type notesLoadedMsg struct {
+	view  int
+	items []list.Item
+	err   error
+}
+
+// opDoneMsg reports a change made through the API. The list is reloaded
+// afterwards, with the cursor on selectID if it is set.
+type opDoneMsg struct {
+	action   string
+	selectID string
+	err      error
+}
+
+type templatesMsg struct {
+	templates []apiTemplate
+	err       error
+}
+
+type calendarMsg struct {
+	month   string
+	entries map[string]bool
+	err     error
+}
+
+type dailyMsg struct {
+	note apiNote
+	err  error
+}
+
+type syncDoneMsg struct {
+	conflicts []conflict
+	err       error
+}
+
+// isNetworkError reports whether err means the server could not be reached,
+// as opposed to the server rejecting a request. Mistakes that retrying
+// cannot fix, such as an untrusted certificate or a malformed URL, are not
+// network errors.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// The connection closed before a response arrived.
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "read")
+}
+
+// statusError turns an unexpected response into an error that includes the
+// server's message.
+func statusError(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("%s: %d %s", action, resp.StatusCode, msg)
+}
+
+func loadNotesCmd(view int) tea.Cmd {
+	return func() tea.Msg {
+		notes, err := local.refresh(noteViews[view])
+		items := make([]list.Item, len(notes))
+		for i, note := range notes {
+			items[i] = newNoteListItem(note)
+		}
+		return notesLoadedMsg{view: view, items: items, err: err}
+	}
+}
+
+func syncCmd() tea.Cmd {
+	return func() tea.Msg {
+		conflicts, err := local.sync()
+		return syncDoneMsg{conflicts: conflicts, err: err}
+	}
+}
+
+// apiCmd runs a change against the server in the background.
+func apiCmd(action string, fn func() error) tea.Cmd {
+	return func() tea.Msg {
+		return opDoneMsg{action: action, err: fn()}
+	}
+}
+
+// apiSelectCmd is like apiCmd for changes that produce a note which should
+// be selected afterwards.
+func apiSelectCmd(action string, fn func() (string, error)) tea.Cmd {
+	return func() tea.Msg {
+		id, err := fn()
+		return opDoneMsg{action: action, selectID: id, err: err}
+	}
+}
+
+func createNote(title, content string) (string, error) {
+	note := apiNote{
+		Title:   title,
+		Content: content,
+	}
+	if err := sealNote(&note); err != nil {
+		return "", fmt.Errorf("encrypting note: %w", err)
+	}
+	return local.create(note)
+}
+
+func updateNote(id, title, content string) error {
+	note := apiNote{
+		ID:      id,
+		Title:   title,
+		Content: content,
+	}
+	if err := sealNote(&note); err != nil {
+		return fmt.Errorf("encrypting note: %w", err)
+	}
+	return local.update(note)
+}
+
+// resolveNote settles a conflict with content, or with the server's copy if
+// keepServer is set.
+func resolveNote(c conflict, content string, keepServer bool) error {
+	if keepServer {
+		return local.resolve(c.server, nil)
+	}
+	note := apiNote{
+		ID:      c.id,
+		Title:   c.title,
+		Content: content,
+	}
+	if err := sealNote(&note); err != nil {
+		return fmt.Errorf("encrypting note: %w", err)
+	}
+	return local.resolve(c.server, &note)
+}
+
+func deleteNote(id string) error {
+	return local.remove(id)
+}
+
+func loadTemplatesCmd() tea.Cmd {
+	return func() tea.Msg {
+		templates, err := api.fetchTemplates()
+		return templatesMsg{templates: templates, err: err}
+	}
+}
+
+func createNoteFromTemplate(templateID, title string, vars map[string]string) error {
+	return api.postFromTemplate(templateID, title, vars)
+}
+
+func summarizeNote(id string) tea.Cmd {
+	return func() tea.Msg {
+		note, err := api.summarize(id)
+		return summaryMsg{id: id, summary: note.Summary, err: err}
+	}
+}
+
+func loadCalendarCmd(month string) tea.Cmd {
+	return func() tea.Msg {
+		entries := map[string]bool{}
+		days, err := api.fetchCalendar(month)
+		for _, day := range days {
+			entries[day] = true
+		}
+		return calendarMsg{month: month, entries: entries, err: err}
+	}
+}
+
+func loadDailyCmd(date string) tea.Cmd {
+	return func() tea.Msg {
+		note, err := api.fetchDaily(date)
+		if err != nil {
+			return dailyMsg{err: err}
+		}
+		openNote(&note)
+		return dailyMsg{note: note}
+	}
+}
+
+// notesAPI is the notes-api server as the client sees it. Everything that
+// talks to the server goes through api, so tests can point it at a fake.
+type notesAPI interface {
+	profileName() string
+	serverURL() string
+
+	fetchNotes(query string) ([]apiNote, error)
+	fetchNote(id string) (apiNote, error)
+	fetchSearch(query string) ([]apiNote, error)
+	postNote(note apiNote, key string) (apiNote, error)
+	putNote(note apiNote) (apiNote, error)
+	removeNote(id string) error
+	toggleFlag(id, flag string) error
+	tagNote(id string, add, remove []string) error
+	summarize(id string) (apiNote, error)
+
+	fetchTemplates() ([]apiTemplate, error)
+	postFromTemplate(templateID, title string, vars map[string]string) error
+	fetchCalendar(month string) ([]string, error)
+	fetchDaily(date string) (apiNote, error)
+}
+
+func (c *apiClient) profileName() string { return c.profile }
+func (c *apiClient) serverURL() string   { return c.baseURL }
+
+// getJSON fetches path and decodes the response into v.
+func (c *apiClient) getJSON(action, path string, v any) error {
+	resp, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(action, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// postJSON sends body to path and decodes the response into v, if v is
+// not nil. Creates answer 201, other actions 200.
+func (c *apiClient) postJSON(action, path string, body []byte, v any) error {
+	resp, err := c.post(path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return statusError(action, resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *apiClient) fetchNotes(query string) ([]apiNote, error) {
+	var notes []apiNote
+	err := c.getJSON("list notes", "/notes"+query, &notes)
+	return notes, err
+}
+
+// fetchNote returns a note by ID. errNotFound means the server has no such
+// note.
+func (c *apiClient) fetchNote(id string) (apiNote, error) {
+	var note apiNote
+	resp, err := c.get("/notes/" + url.PathEscape(id))
+	if err != nil {
+		return note, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return note, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return note, statusError("load note", resp)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&note)
+	return note, err
+}
+
+// fetchSearch returns the server's search results for query. errNotFound
+// means the server has no search endpoint.
+func (c *apiClient) fetchSearch(query string) ([]apiNote, error) {
+	resp, err := c.get("/search?q=" + url.QueryEscape(query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("search", resp)
+	}
+	var notes []apiNote
+	err = json.NewDecoder(resp.Body).Decode(&notes)
+	return notes, err
+}
+
+// postNote creates a note. A non-empty key is sent as Idempotency-Key, so
+// the server creates the note only once however often it is sent with the
+// same key.
+func (c *apiClient) postNote(note apiNote, key string) (apiNote, error) {
+	note.ID = ""
+	body, _ := json.Marshal(note)
+	var header http.Header
+	if key != "" {
+		header = http.Header{"Idempotency-Key": {key}}
+	}
+	resp, err := c.request(http.MethodPost, "/notes", header, body)
+	if err != nil {
+		return note, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return note, statusError("create note", resp)
+	}
+	var created apiNote
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return note, err
+	}
+	return created, nil
+}
+
+func (c *apiClient) putNote(note apiNote) (apiNote, error) {
+	body, _ := json.Marshal(note)
+	resp, err := c.do(http.MethodPut, "/notes/"+note.ID, body)
+	if err != nil {
+		return note, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return note, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return note, statusError("update note", resp)
+	}
+	var updated apiNote
+	err = json.NewDecoder(resp.Body).Decode(&updated)
+	return updated, err
+}
+
+func (c *apiClient) removeNote(id string) error {
+	resp, err := c.do(http.MethodDelete, "/notes/"+id, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	// Servers before 204 responses answered 200.
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return statusError("delete note", resp)
+	}
+	return nil
+}
+
+func (c *apiClient) toggleFlag(id, flag string) error {
+	return c.postJSON(flag, "/notes/"+id+"/"+flag, nil, nil)
+}
+
+// tagNote adds and removes tags of a note.
+func (c *apiClient) tagNote(id string, add, remove []string) error {
+	body, err := json.Marshal(map[string][]string{"add": add, "remove": remove})
+	if err != nil {
+		return err
+	}
+	return c.postJSON("tag", "/notes/"+id+"/tags", body, nil)
+}
+
+func (c *apiClient) summarize(id string) (apiNote, error) {
+	var note apiNote
+	err := c.postJSON("summarize", "/notes/"+id+"/summarize", nil, &note)
+	return note, err
+}
+
+func (c *apiClient) fetchTemplates() ([]apiTemplate, error) {
+	var templates []apiTemplate
+	err := c.getJSON("load templates", "/templates", &templates)
+	return templates, err
+}
+
+func (c *apiClient) postFromTemplate(templateID, title string, vars map[string]string) error {
+	body, _ := json.Marshal(map[string]any{
+		"title": title,
+		"user":  os.Getenv("USER"),
+		"vars":  vars,
+	})
+	return c.postJSON("create from template", "/notes?template="+url.QueryEscape(templateID), body, nil)
+}
+
+// fetchCalendar returns the days of a month that have a daily note.
+func (c *apiClient) fetchCalendar(month string) ([]string, error) {
+	var cal calendarResponse
+	err := c.getJSON("load calendar", "/calendar?month="+month, &cal)
+	return cal.Days, err
+}
+
+func (c *apiClient) fetchDaily(date string) (apiNote, error) {
+	var note apiNote
+	err := c.getJSON("open daily note", "/daily/"+date, &note)
+	return note, err
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsNetworkError(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+	lost := newFakeServer(t)
+	lost.loseCreates(1)
+
+	for _, tc := range []struct {
+		name, method, url string
+		want              bool
+	}{
+		{"connection refused", http.MethodGet, closed.URL + "/notes", true},
+		{"connection closed", http.MethodPost, lost.URL + "/notes", true},
+		{"untrusted certificate", http.MethodGet, untrusted.URL + "/notes", false},
+		{"unsupported scheme", http.MethodGet, "ftp://notes.test/notes", false},
+		{"malformed URL", http.MethodGet, "http://[::1/notes", false},
+	} {
+		req, err := http.NewRequest(tc.method, tc.url, strings.NewReader("{}"))
+		if err == nil {
+			_, err = http.DefaultClient.Do(req)
+		}
+		if err == nil {
+			t.Fatalf("%s: no error", tc.name)
+		}
+		if got := isNetworkError(err); got != tc.want {
+			t.Errorf("%s: isNetworkError(%v) = %v", tc.name, err, got)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultAutosave is how long typing must pause before a tab is saved.
+const defaultAutosave = 2 * time.Second
+
+// autosaveDelay is the pause before a tab is saved, set up by setup from
+// the "autosave" config. Zero turns autosave off; the crash journal is still
+// written after defaultAutosave.
+var autosaveDelay = defaultAutosave
+
+// parseAutosave reads the "autosave" config: a delay such as "5s", or "off".
+func parseAutosave(value string) (time.Duration, error) {
+	switch value {
+	case "":
+		return defaultAutosave, nil
+	case "off":
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid autosave delay %q, want a duration such as \"2s\" or \"off\"", value)
+	}
+	return d, nil
+}
+
+// autosaveMsg fires once typing in a tab has paused. Ticks for an older
+// edit than the tab's latest carry an old gen and are ignored.
+type autosaveMsg struct {
+	id  string
+	gen int
+}
+
+// draftsRestoredMsg reports drafts restored from the crash journal.
+type draftsRestoredMsg struct{ n int }
+
+// scheduleAutosave starts the pause after an edit to a tab.
+func (m *model) scheduleAutosave(t *tab) tea.Cmd {
+	t.edits++
+	id, gen := t.id, t.edits
+	delay := autosaveDelay
+	if delay == 0 {
+		delay = defaultAutosave
+	}
+	return after(delay, func(time.Time) tea.Msg { return autosaveMsg{id: id, gen: gen} })
+}
+
+// handleAutosave journals unsaved tabs and saves the one that was edited.
+func (m *model) handleAutosave(msg autosaveMsg) tea.Cmd {
+	i := m.openTab(msg.id)
+	if i < 0 || m.tabs[i].edits != msg.gen {
+		return nil
+	}
+	m.writeJournal()
+	t := m.tabs[i]
+	if autosaveDelay == 0 || !t.dirty() || t.locked {
+		return nil
+	}
+	return m.saveTab(t)
+}
+
+// journalFile is the crash journal: drafts of tabs with unsaved changes,
+// kept in the user's cache directory next to the cache of the same profile
+// and server. Drafts are stored in their wire form, so encrypted notes stay
+// encrypted on disk.
+type journalFile struct {
+	SavedAt time.Time `json:"saved_at"`
+	Drafts  []apiNote `json:"drafts"`
+}
+
+func journalPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "notes-cli", cacheName(api.profileName(), api.serverURL())+".journal.json")
+}
+
+// journalGen numbers journal writes in the order the UI asks for them. They
+// run as commands, so one may finish after a later one; journalWritten
+// holds the latest written, and older writes are dropped.
+var (
+	journalGen     int
+	journalWritten struct {
+		sync.Mutex
+		gen int
+	}
+)
+
+// writeJournal marks the journal to be written once the current message is
+// handled; see Update.
+func (m *model) writeJournal() {
+	m.journalDue = true
+}
+
+// journalCmd records the tabs with unsaved changes in the journal, or
+// removes it when there are none. Sealing drafts takes a key derivation with
+// a passphrase, so the drafts are taken now and sealed by the command.
+func (m *model) journalCmd() tea.Cmd {
+	m.journalDue = false
+	var drafts []apiNote
+	for _, t := range m.tabs {
+		if t.dirty() && !t.locked {
+			drafts = append(drafts, apiNote{ID: t.id, Title: t.title, Content: t.editor.Value()})
+		}
+	}
+	return saveJournalCmd(drafts)
+}
+
+// quitCmd writes the journal and then quits, so the program does not exit
+// before the drafts are on disk.
+func (m *model) quitCmd() tea.Cmd {
+	write := m.journalCmd()
+	return func() tea.Msg {
+		write()
+		return tea.QuitMsg{}
+	}
+}
+
+// saveJournalCmd writes drafts, given in plaintext, as the journal.
+func saveJournalCmd(drafts []apiNote) tea.Cmd {
+	journalGen++
+	gen := journalGen
+	return func() tea.Msg {
+		journalWritten.Lock()
+		defer journalWritten.Unlock()
+		if gen < journalWritten.gen {
+			return nil
+		}
+		journalWritten.gen = gen
+		saveJournal(drafts)
+		return nil
+	}
+}
+
+func saveJournal(drafts []apiNote) {
+	path := journalPath()
+	if path == "" {
+		return
+	}
+	j := journalFile{SavedAt: time.Now()}
+	for _, note := range drafts {
+		if err := sealNote(&note); err != nil {
+			log.Printf("Error encrypting draft: %v", err)
+			continue
+		}
+		j.Drafts = append(j.Drafts, note)
+	}
+
+	if len(j.Drafts) == 0 {
+		removeJournal()
+		return
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		log.Printf("Error encoding journal: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Printf("Error creating cache directory: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Error writing journal: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("Error writing journal: %v", err)
+	}
+}
+
+// removeJournal drops the journal, discarding its drafts.
+func removeJournal() {
+	path := journalPath()
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing journal: %v", err)
+	}
+}
+
+// restoreDrafts opens a tab for each draft left in the journal by a session
+// that did not exit cleanly. The cached copy of each note is the saved
+// content, so restored tabs show as unsaved.
+func restoreDrafts() []tab {
+	path := journalPath()
+	if path == "" {
+		return nil
+	}
+	// Journals used to be named after the profile alone, like caches.
+	legacy := filepath.Join(filepath.Dir(path), api.profileName()+".journal.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		os.Rename(legacy, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading journal: %v", err)
+		}
+		return nil
+	}
+	var j journalFile
+	if err := json.Unmarshal(data, &j); err != nil {
+		log.Printf("Error decoding journal: %v", err)
+		return nil
+	}
+
+	var tabs []tab
+	for _, draft := range j.Drafts {
+		if !openNote(&draft) {
+			continue
+		}
+		local.mu.Lock()
+		base, cached := local.Notes[draft.ID]
+		local.mu.Unlock()
+		saved := ""
+		if cached && openNote(&base) {
+			saved = base.Content
+		}
+		if draft.Content == saved {
+			continue
+		}
+		t := tab{id: draft.ID, title: draft.Title, saved: saved, editor: newEditor()}
+		t.editor.SetValue(draft.Content)
+		tabs = append(tabs, t)
+	}
+	return tabs
+}
+
+// quit exits, first asking what to do with unsaved changes and unresolved
+// conflicts. Quitting again while asked exits anyway; the drafts stay in the
+// journal and are restored on the next launch, and queued conflicts come
+// back with the next sync.
+func (m *model) quit() tea.Cmd {
+	if m.unsaved == "quit" || m.dirtyTabs() == 0 && len(m.conflicts) == 0 {
+		return m.quitCmd()
+	}
+	m.unsaved = "quit"
+	return nil
+}
+
+// updateUnsaved handles keys in the unsaved changes prompt, which is shown
+// before quitting or closing a tab with unsaved changes.
+func (m model) updateUnsaved(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	k := keys.Unsaved
+	action := m.unsaved
+	switch {
+	case key.Matches(msg, k.Cancel):
+		m.unsaved = ""
+	case key.Matches(msg, k.Discard):
+		m.unsaved = ""
+		if action == "quit" {
+			discard := saveJournalCmd(nil)
+			return m, func() tea.Msg {
+				discard()
+				return tea.QuitMsg{}
+			}
+		}
+		m.removeTab(m.active)
+		m.selectNote(m.activeTab().id)
+	case key.Matches(msg, k.Save):
+		m.unsaved = ""
+		if action == "quit" {
+			if m.dirtyTabs() == 0 {
+				return m, m.quitCmd()
+			}
+			m.quitting = true
+			return m, m.saveAll()
+		}
+		t := m.activeTab()
+		if !t.locked {
+			m.closing = t.id
+		}
+		return m, m.saveTab(*t)
+	}
+	return m, nil
+}
+
+// unsavedView is the unsaved changes prompt.
+func (m model) unsavedView() string {
+	question := fmt.Sprintf("%q has unsaved changes.", m.activeTab().title)
+	k := keys.Unsaved
+	help := shortHelp(k.Save, k.Discard, k.Cancel)
+	if m.unsaved == "quit" {
+		var lines []string
+		switch n := m.dirtyTabs(); n {
+		case 0:
+			help = shortHelp(k.Discard, k.Cancel)
+		case 1:
+			lines = append(lines, "1 note has unsaved changes.")
+		default:
+			lines = append(lines, fmt.Sprintf("%d notes have unsaved changes.", n))
+		}
+		switch n := len(m.conflicts); n {
+		case 0:
+		case 1:
+			lines = append(lines, "1 conflict is unresolved.")
+		default:
+			lines = append(lines, fmt.Sprintf("%d conflicts are unresolved.", n))
+		}
+		question = strings.Join(lines, "\n")
+	}
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		ui.dialog.Render(
+			lipgloss.JoinVertical(
+				lipgloss.Center,
+				question,
+				ui.muted.Render(help),
+			),
+		),
+	)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+type calendarResponse struct {
+	Month string   `json:"month"`
+	Days  []string `json:"days"`
+}
+
+// calendar is a month view used to jump between daily notes.
+type calendar struct {
+	selected time.Time
+	entries  map[string]bool
+}
+
+func newCalendar(now time.Time) calendar {
+	return calendar{
+		selected: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local),
+		entries:  map[string]bool{},
+	}
+}
+
+func (c calendar) month() string {
+	return c.selected.Format(monthLayout)
+}
+
+// move shifts the selection by days and months. It returns a command to load
+// the entries when the selection lands in a different month.
+func (c *calendar) move(months, days int) tea.Cmd {
+	prev := c.month()
+	c.selected = c.selected.AddDate(0, months, days)
+	if c.month() == prev {
+		return nil
+	}
+	c.entries = map[string]bool{}
+	return loadCalendarCmd(c.month())
+}
+
+func (m model) updateCalendar(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	k := keys.Calendar
+	switch {
+	case key.Matches(msg, k.Left):
+		cmd = m.calendar.move(0, -1)
+	case key.Matches(msg, k.Right):
+		cmd = m.calendar.move(0, 1)
+	case key.Matches(msg, k.Up):
+		cmd = m.calendar.move(0, -7)
+	case key.Matches(msg, k.Down):
+		cmd = m.calendar.move(0, 7)
+	case key.Matches(msg, k.PrevMonth):
+		cmd = m.calendar.move(-1, 0)
+	case key.Matches(msg, k.NextMonth):
+		cmd = m.calendar.move(1, 0)
+	case key.Matches(msg, k.Back):
+		m.focus = "list"
+	case key.Matches(msg, k.Open):
+		cmd = loadDailyCmd(m.calendar.selected.Format(dayLayout))
+	}
+	if cmd != nil {
+		cmd = m.run(cmd)
+	}
+	return m, cmd
+}
+
+func (c calendar) View() string {
+	var b strings.Builder
+	first := time.Date(c.selected.Year(), c.selected.Month(), 1, 0, 0, 0, 0, time.Local)
+	today := time.Now().Format(dayLayout)
+
+	b.WriteString(lipgloss.PlaceHorizontal(20, lipgloss.Center, first.Format("January 2006")))
+	b.WriteString("\nMo Tu We Th Fr Sa Su\n")
+
+	// Weeks start on Monday.
+	offset := (int(first.Weekday()) + 6) % 7
+	b.WriteString(strings.Repeat("   ", offset))
+
+	for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dayLayout)
+		cell := fmt.Sprintf("%2d", d.Day())
+		if c.entries[key] {
+			cell = ui.accent.Render(cell)
+		}
+		if key == today {
+			cell = ui.today.Render(cell)
+		}
+		if d.Equal(c.selected) {
+			cell = ui.selected.Render(cell)
+		}
+		b.WriteString(cell)
+
+		if d.Weekday() == time.Sunday {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+
+	b.WriteString("\n\n")
+	k := keys.Calendar
+	b.WriteString(ui.muted.Render(strings.Join([]string{
+		shortHelp(k.PrevMonth, k.NextMonth),
+		shortHelp(k.Open),
+		shortHelp(k.Back),
+	}, "\n")))
+	return b.String()
+}
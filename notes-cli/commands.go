@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes of the non-interactive commands.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitUnavailable = 4
+)
+
+// usageError is a mistake in the command line, reported with exit code 2.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// cli runs one non-interactive command. stdinPiped is set when stdin is not
+// a terminal, in which case it holds note content.
+type cli struct {
+	stdin          io.Reader
+	stdinPiped     bool
+	stdout, stderr io.Writer
+}
+
+type command struct {
+	name, args, summary string
+	run                 func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"list", "[-view all|favorites|archived] [-format table|json|markdown]", "list notes", (*cli).list},
+	{"show", "[-format table|json|markdown] <id>", "print a note", (*cli).show},
+	{"new", "-title <title> [-idempotency-key <key>] [-format table|json|markdown] < content", "create a note, reading its content from stdin", (*cli).newNote},
+	{"edit", "[-title <title>] [-append|-clear] <id> [< content]", "rename a note, or replace its content from stdin or in $EDITOR", (*cli).edit},
+	{"rm", "<id>...", "delete notes", (*cli).remove},
+	{"search", "[-format table|json|markdown] <query>", "find notes by title and content", (*cli).search},
+}
+
+// runCommand runs the subcommand named by args[0] and returns the process
+// exit code. Notes can be given by ID or by a unique prefix of it.
+func runCommand(args []string, stdin io.Reader, stdinPiped bool, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdinPiped: stdinPiped, stdout: stdout, stderr: stderr}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(c, args[1:])
+		if err == nil {
+			return exitOK
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "notes-cli %s: %v\n", cmd.name, err)
+
+		var usage usageError
+		switch {
+		case errors.As(err, &usage):
+			fmt.Fprintf(stderr, "usage: notes-cli %s %s\n", cmd.name, cmd.args)
+			return exitUsage
+		case errors.Is(err, errNotFound):
+			return exitNotFound
+		case isNetworkError(err):
+			return exitUnavailable
+		default:
+			return exitError
+		}
+	}
+
+	fmt.Fprintf(stderr, "notes-cli: unknown command %q\n\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: notes-cli [-profile name] [-server url] [command]")
+	fmt.Fprintln(w, "\nWithout a command, notes-cli starts the interactive UI. Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("notes-cli "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "table", "output format: table, json or markdown")
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case "table", "json", "markdown":
+		return nil
+	}
+	return usageError{fmt.Sprintf("unknown format %q", format)}
+}
+
+func (c *cli) list(args []string) error {
+	fs := c.flags("list")
+	format := formatFlag(fs)
+	viewName := fs.String("view", "all", "notes to list: all, favorites or archived")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	view := -1
+	for i, v := range noteViews {
+		if v.name == *viewName {
+			view = i
+		}
+	}
+	if view < 0 {
+		return usageError{fmt.Sprintf("unknown view %q", *viewName)}
+	}
+
+	notes, err := api.fetchNotes(noteViews[view].query)
+	if err != nil {
+		return err
+	}
+	return c.printNotes(*format, openNotes(notes))
+}
+
+func (c *cli) show(args []string) error {
+	fs := c.flags("show")
+	format := formatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{"expected one note ID"}
+	}
+
+	note, err := findNote(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	openNote(&note)
+	return c.printNote(*format, note)
+}
+
+func (c *cli) newNote(args []string) error {
+	fs := c.flags("new")
+	format := formatFlag(fs)
+	title := fs.String("title", "", "title of the note")
+	key := fs.String("idempotency-key", "", "create the note only once however often the command is run with this key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if *title == "" || fs.NArg() > 0 {
+		return usageError{"expected a -title and no arguments"}
+	}
+
+	note := apiNote{Title: *title}
+	if c.stdinPiped {
+		content, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		note.Content = string(content)
+	}
+	if *key == "" {
+		*key = newIdempotencyKey()
+	}
+	return c.save(*format, note, func(n apiNote) (apiNote, error) {
+		return api.postNote(n, *key)
+	})
+}
+
+func (c *cli) edit(args []string) error {
+	fs := c.flags("edit")
+	format := formatFlag(fs)
+	title := fs.String("title", "", "new title of the note")
+	appendContent := fs.Bool("append", false, "append stdin to the content instead of replacing it")
+	clearContent := fs.Bool("clear", false, "empty the content")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError{"expected one note ID"}
+	}
+	if *appendContent && !c.stdinPiped {
+		return usageError{"-append needs content on stdin"}
+	}
+	if *appendContent && *clearContent {
+		return usageError{"-append and -clear cannot be combined"}
+	}
+
+	note, err := findNote(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Saving a locked note would replace its ciphertext with what is left
+	// of it after openNote: nothing, or plaintext still marked encrypted.
+	if !openNote(&note) {
+		return errors.New("note could not be decrypted; is NOTES_PASSPHRASE set?")
+	}
+
+	// Stdin is only read for content when content is asked for: a rename
+	// run in a loop or a hook must not eat its input or empty the note.
+	switch {
+	case *clearContent:
+		note.Content = ""
+	case *appendContent || c.stdinPiped && *title == "":
+		content, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		if len(content) == 0 {
+			return usageError{"no content on stdin; use -clear to empty the note"}
+		}
+		if *appendContent {
+			if note.Content != "" && !strings.HasSuffix(note.Content, "\n") {
+				note.Content += "\n"
+			}
+			note.Content += string(content)
+		} else {
+			note.Content = string(content)
+		}
+	case *title == "":
+		content, err := editInEditor(note.Content, c.stderr)
+		if err != nil {
+			return err
+		}
+		if content == note.Content {
+			fmt.Fprintln(c.stderr, "No changes")
+			return nil
+		}
+		note.Content = content
+	}
+	if *title != "" {
+		note.Title = *title
+	}
+	return c.save(*format, note, api.putNote)
+}
+
+// editInEditor opens content in the user's editor and returns the result.
+func editInEditor(content string, stderr io.Writer) (string, error) {
+	path, err := writeTempNote(content)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	cmd := editorCommand(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// save encrypts a note if a passphrase is set, sends it with send and
+// prints the result.
+func (c *cli) save(format string, note apiNote, send func(apiNote) (apiNote, error)) error {
+	if err := sealNote(&note); err != nil {
+		return fmt.Errorf("encrypting note: %w", err)
+	}
+	saved, err := send(note)
+	if err != nil {
+		return err
+	}
+	openNote(&saved)
+	if format == "table" {
+		fmt.Fprintln(c.stdout, saved.ID)
+		return nil
+	}
+	return c.printNote(format, saved)
+}
+
+func (c *cli) remove(args []string) error {
+	fs := c.flags("rm")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError{"expected at least one note ID"}
+	}
+
+	for _, arg := range fs.Args() {
+		note, err := findNote(arg)
+		if err != nil {
+			return err
+		}
+		if err := api.removeNote(note.ID); err != nil {
+			return fmt.Errorf("%s: %w", note.ID, err)
+		}
+	}
+	return nil
+}
+
+func (c *cli) search(args []string) error {
+	fs := c.flags("search")
+	format := formatFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return usageError{"expected a search query"}
+	}
+
+	notes, err := searchNotes(query)
+	if err != nil {
+		return err
+	}
+	return c.printNotes(*format, notes)
+}
+
+// searchNotes uses the server's search endpoint, or fuzzy-matches all notes
+// locally when the server has none.
+func searchNotes(query string) ([]apiNote, error) {
+	notes, err := api.fetchSearch(query)
+	if !errors.Is(err, errNotFound) {
+		return openNotes(notes), err
+	}
+
+	all, err := fetchAllNotes()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]apiNote{}
+	items := make([]noteListItem, len(all))
+	for i, n := range all {
+		byID[n.ID] = n
+		items[i] = noteListItem{id: n.ID, title: n.Title, content: n.Content}
+	}
+	notes = nil
+	for _, r := range matchNotes(query, items) {
+		notes = append(notes, byID[r.id])
+	}
+	return notes, nil
+}
+
+// fetchAllNotes returns every note, archived ones included, decrypted where
+// possible.
+func fetchAllNotes() ([]apiNote, error) {
+	notes, err := fetchSealedNotes()
+	return openNotes(notes), err
+}
+
+// fetchSealedNotes returns every note as the server stores it.
+func fetchSealedNotes() ([]apiNote, error) {
+	notes, err := api.fetchNotes("")
+	if err != nil {
+		return nil, err
+	}
+	archived, err := api.fetchNotes("?archived=true")
+	if err != nil {
+		return nil, err
+	}
+	return append(notes, archived...), nil
+}
+
+// findNote returns the note whose ID is id or starts with it, still
+// encrypted; callers open it.
+func findNote(id string) (apiNote, error) {
+	notes, err := fetchSealedNotes()
+	if err != nil {
+		return apiNote{}, err
+	}
+	var matches []apiNote
+	for _, n := range notes {
+		if n.ID == id {
+			return n, nil
+		}
+		if strings.HasPrefix(n.ID, id) {
+			matches = append(matches, n)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return apiNote{}, fmt.Errorf("%s: %w", id, errNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	return apiNote{}, fmt.Errorf("%s: ambiguous ID, matches %d notes", id, len(matches))
+}
+
+func openNotes(notes []apiNote) []apiNote {
+	for i := range notes {
+		openNote(&notes[i])
+	}
+	return notes
+}
+
+func noteFlags(n apiNote) string {
+	var flags []string
+	if n.Pinned {
+		flags = append(flags, "pinned")
+	}
+	if n.Favorite {
+		flags = append(flags, "favorite")
+	}
+	if n.Archived {
+		flags = append(flags, "archived")
+	}
+	if n.Encrypted {
+		flags = append(flags, "encrypted")
+	}
+	return strings.Join(flags, ",")
+}
+
+func (c *cli) printNotes(format string, notes []apiNote) error {
+	switch format {
+	case "json":
+		if notes == nil {
+			notes = []apiNote{}
+		}
+		return c.printJSON(notes)
+	case "markdown":
+		fmt.Fprintln(c.stdout, "| ID | Title | Updated | Flags |")
+		fmt.Fprintln(c.stdout, "| --- | --- | --- | --- |")
+		for _, n := range notes {
+			title := strings.ReplaceAll(n.Title, "|", `\|`)
+			fmt.Fprintf(c.stdout, "| %s | %s | %s | %s |\n", n.ID, title, n.UpdatedAt.Format(time.DateTime), noteFlags(n))
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tFLAGS\tTITLE")
+	for _, n := range notes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", n.ID, n.UpdatedAt.Format(time.DateTime), noteFlags(n), n.Title)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) printNote(format string, n apiNote) error {
+	switch format {
+	case "json":
+		return c.printJSON(n)
+	case "markdown":
+		fmt.Fprintf(c.stdout, "# %s\n\n%s", n.Title, n.Content)
+		if !strings.HasSuffix(n.Content, "\n") {
+			fmt.Fprintln(c.stdout)
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", n.ID)
+	fmt.Fprintf(tw, "Title:\t%s\n", n.Title)
+	fmt.Fprintf(tw, "Created:\t%s\n", n.CreatedAt.Format(time.DateTime))
+	fmt.Fprintf(tw, "Updated:\t%s\n", n.UpdatedAt.Format(time.DateTime))
+	if flags := noteFlags(n); flags != "" {
+		fmt.Fprintf(tw, "Flags:\t%s\n", flags)
+	}
+	if len(n.Tags) > 0 {
+		fmt.Fprintf(tw, "Tags:\t%s\n", strings.Join(n.Tags, ", "))
+	}
+	if n.Summary != "" {
+		fmt.Fprintf(tw, "Summary:\t%s\n", n.Summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "\n%s", n.Content)
+	if !strings.HasSuffix(n.Content, "\n") {
+		fmt.Fprintln(c.stdout)
+	}
+	return nil
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
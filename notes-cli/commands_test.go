@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runCLI runs a command against srv and returns its exit code and stderr.
+func runCLI(t *testing.T, srv *fakeServer, stdin string, args ...string) (int, string) {
+	t.Helper()
+	c, err := newAPIClient("test", profile{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = c
+	var stdout, stderr bytes.Buffer
+	code := runCommand(args, strings.NewReader(stdin), stdin != "", &stdout, &stderr)
+	return code, stderr.String()
+}
+
+func TestEditLockedNote(t *testing.T) {
+	sealed := newVault("right", false)
+	note := apiNote{Title: "Diary", Content: "Dear diary"}
+	if err := sealed.seal(&note); err != nil {
+		t.Fatal(err)
+	}
+	srv := newFakeServer(t, note)
+	t.Cleanup(func() { notesVault = nil })
+
+	for _, tc := range []struct {
+		name  string
+		vault *vault
+		stdin string
+		args  []string
+	}{
+		{"rename without passphrase", nil, "", []string{"edit", "-title", "Renamed", "note-1"}},
+		{"replace without passphrase", nil, "plaintext", []string{"edit", "note-1"}},
+		{"append with wrong passphrase", newVault("wrong", false), "more", []string{"edit", "-append", "note-1"}},
+	} {
+		notesVault = tc.vault
+		code, stderr := runCLI(t, srv, tc.stdin, tc.args...)
+		if code != exitError || !strings.Contains(stderr, "could not be decrypted") {
+			t.Errorf("%s: exit %d, %q", tc.name, code, stderr)
+		}
+		if got, _ := srv.note("note-1"); got.Content != note.Content || got.Title != note.Title {
+			t.Errorf("%s: stored %+v", tc.name, got)
+		}
+	}
+
+	notesVault = sealed
+	if code, stderr := runCLI(t, srv, "", "edit", "-title", "Renamed", "note-1"); code != exitOK {
+		t.Fatalf("unlocked edit: exit %d, %q", code, stderr)
+	}
+	got, _ := srv.note("note-1")
+	if !openNote(&got) || got.Title != "Renamed" || got.Content != "Dear diary" {
+		t.Errorf("after unlocked edit %+v", got)
+	}
+}
+
+func TestEditContentOnlyWhenAsked(t *testing.T) {
+	srv := newFakeServer(t, apiNote{Title: "Groceries", Content: "Milk"})
+	c, err := newAPIClient("test", profile{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = c
+	edit := func(stdin string, args ...string) int {
+		in := strings.NewReader(stdin)
+		var stdout, stderr bytes.Buffer
+		code := runCommand(append([]string{"edit"}, args...), in, true, &stdout, &stderr)
+		if args[0] == "-title" && in.Len() != len(stdin) {
+			t.Errorf("edit %q read stdin", args)
+		}
+		return code
+	}
+
+	// A rename leaves stdin, and the content, alone.
+	if code := edit("note-2\nnote-3\n", "-title", "Shopping", "note-1"); code != exitOK {
+		t.Fatalf("rename: exit %d", code)
+	}
+	if n, _ := srv.note("note-1"); n.Title != "Shopping" || n.Content != "Milk" {
+		t.Errorf("after rename %+v", n)
+	}
+
+	// Empty stdin does not empty the note; -clear does.
+	if code := edit("", "note-1"); code != exitUsage {
+		t.Errorf("empty stdin: exit %d", code)
+	}
+	if n, _ := srv.note("note-1"); n.Content != "Milk" {
+		t.Errorf("empty stdin stored %q", n.Content)
+	}
+	if code := edit("", "-clear", "note-1"); code != exitOK {
+		t.Fatalf("clear: exit %d", code)
+	}
+	if n, _ := srv.note("note-1"); n.Content != "" {
+		t.Errorf("after -clear %q", n.Content)
+	}
+}
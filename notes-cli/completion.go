@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"autocomplete/ollamastream"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	defaultCompletionEndpoint = "http://localhost:11434/api/generate"
+	defaultCompletionModel    = "llama3.2"
+	defaultCompletionDelay    = 500 * time.Millisecond
+	completionTimeout         = 30 * time.Second
+	// completionBefore and completionAfter bound the text around the cursor
+	// sent as context, in runes.
+	completionBefore = 2000
+	completionAfter  = 500
+	// completionLines is the height of the suggestion line below the editor.
+	completionLines = 1
+)
+
+// completionConfig is the "completion" section of the config file, e.g.
+//
+//	"completion": {"enabled": true, "model": "llama3.2", "delay": "500ms"}
+//
+// The endpoint defaults to a local Ollama server. OLLAMA_ENDPOINT and
+// OLLAMA_MODEL override the config, and NOTES_COMPLETION=on or off turns
+// completion on or off.
+type completionConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Delay    string `json:"delay,omitempty"`
+}
+
+type completionSettings struct {
+	enabled         bool
+	endpoint, model string
+	delay           time.Duration
+}
+
+// completion holds the inline completion settings, set up by setup.
+var completion = completionSettings{
+	endpoint: defaultCompletionEndpoint,
+	model:    defaultCompletionModel,
+	delay:    defaultCompletionDelay,
+}
+
+func loadCompletion(cfg completionConfig) (completionSettings, error) {
+	s := completionSettings{
+		enabled:  cfg.Enabled,
+		endpoint: envOr("OLLAMA_ENDPOINT", cfg.Endpoint, defaultCompletionEndpoint),
+		model:    envOr("OLLAMA_MODEL", cfg.Model, defaultCompletionModel),
+		delay:    defaultCompletionDelay,
+	}
+	switch env := os.Getenv("NOTES_COMPLETION"); env {
+	case "":
+	case "on":
+		s.enabled = true
+	case "off":
+		s.enabled = false
+	default:
+		return s, fmt.Errorf("invalid NOTES_COMPLETION %q, want \"on\" or \"off\"", env)
+	}
+	if cfg.Delay != "" {
+		d, err := time.ParseDuration(cfg.Delay)
+		if err != nil || d <= 0 {
+			return s, fmt.Errorf("invalid completion delay %q", cfg.Delay)
+		}
+		s.delay = d
+	}
+	return s, nil
+}
+
+// envOr returns the environment variable if set, else the configured value,
+// else the default.
+func envOr(name, value, def string) string {
+	if env := os.Getenv(name); env != "" {
+		return env
+	}
+	if value != "" {
+		return value
+	}
+	return def
+}
+
+// suggestion is the inline completion for the active tab. A new edit bumps
+// gen, so ticks and tokens of an older request are ignored.
+type suggestion struct {
+	gen     int
+	tabID   string
+	text    string
+	loading bool
+	// failed is set after a failed request, so that an unreachable server
+	// is only reported once.
+	failed bool
+	cancel context.CancelFunc
+}
+
+// suggestTickMsg fires once typing has paused long enough to ask for a
+// completion.
+type suggestTickMsg struct{ gen int }
+
+// suggestMsg carries a streamed token, or the end of the stream. next
+// delivers the following message.
+type suggestMsg struct {
+	gen   int
+	token string
+	done  bool
+	err   error
+	next  <-chan suggestMsg
+}
+
+// scheduleSuggestion starts the pause after an edit.
+func (m *model) scheduleSuggestion() tea.Cmd {
+	if !completion.enabled {
+		return nil
+	}
+	m.suggest.gen++
+	gen := m.suggest.gen
+	return after(completion.delay, func(time.Time) tea.Msg { return suggestTickMsg{gen: gen} })
+}
+
+// dismissSuggestion hides the suggestion and cancels a request in flight.
+func (m *model) dismissSuggestion() {
+	if m.suggest.cancel != nil {
+		m.suggest.cancel()
+	}
+	m.suggest.gen++
+	m.suggest.tabID, m.suggest.text = "", ""
+	m.suggest.loading, m.suggest.cancel = false, nil
+}
+
+// suggestionShown reports whether a suggestion for the active tab is shown.
+func (m model) suggestionShown() bool {
+	return m.suggest.text != "" && m.suggest.tabID == m.activeTab().id
+}
+
+// acceptSuggestion inserts the suggestion at the cursor.
+func (m *model) acceptSuggestion() tea.Cmd {
+	t := m.activeTab()
+	t.editor.InsertString(m.suggest.text)
+	m.dismissSuggestion()
+	return m.scheduleAutosave(t)
+}
+
+// handleSuggestTick asks for a completion of the text around the cursor.
+func (m *model) handleSuggestTick(msg suggestTickMsg) tea.Cmd {
+	t := m.activeTab()
+	if msg.gen != m.suggest.gen || m.focus != "content" || t.id == "" || t.locked {
+		return nil
+	}
+	before, after := cursorText(t.editor)
+	if strings.TrimSpace(before) == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	m.suggest.tabID, m.suggest.text = t.id, ""
+	m.suggest.loading, m.suggest.cancel = true, cancel
+	return streamSuggestion(ctx, msg.gen, completionPrompt(before, after))
+}
+
+func (m *model) handleSuggestion(msg suggestMsg) tea.Cmd {
+	if msg.gen != m.suggest.gen {
+		return nil
+	}
+	switch {
+	case msg.err != nil:
+		m.suggest.loading = false
+		if errors.Is(msg.err, context.Canceled) || m.suggest.failed {
+			return nil
+		}
+		m.suggest.failed = true
+		return m.notify(fmt.Sprintf("Completion failed: %v", msg.err), true)
+	case msg.done:
+		m.suggest.loading, m.suggest.failed = false, false
+		return nil
+	}
+
+	// Only the rest of the current line is suggested.
+	m.suggest.text += msg.token
+	if i := strings.IndexByte(m.suggest.text, '\n'); i >= 0 && strings.TrimSpace(m.suggest.text[:i]) != "" {
+		m.suggest.text = m.suggest.text[:i]
+		m.suggest.loading, m.suggest.failed = false, false
+		m.suggest.cancel()
+		return nil
+	}
+	return waitSuggestion(msg.next)
+}
+
+// streamSuggestion sends the prompt to Ollama and delivers each token as a
+// suggestMsg. The stream stops when ctx is cancelled.
+func streamSuggestion(ctx context.Context, gen int, prompt string) tea.Cmd {
+	ch := make(chan suggestMsg)
+	send := func(msg suggestMsg) {
+		msg.gen = gen
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(ch)
+		err := ollamastream.GenerateStream(
+			ctx,
+			prompt,
+			completion.endpoint,
+			completion.model,
+			0.7,
+			40,
+			func(token string) { send(suggestMsg{token: token}) },
+		)
+		if ctx.Err() != nil {
+			return
+		}
+		send(suggestMsg{done: err == nil, err: err})
+	}()
+	return waitSuggestion(ch)
+}
+
+func waitSuggestion(ch <-chan suggestMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		msg.next = ch
+		return msg
+	}
+}
+
+func completionPrompt(before, after string) string {
+	if r := []rune(before); len(r) > completionBefore {
+		before = string(r[len(r)-completionBefore:])
+	}
+	if r := []rune(after); len(r) > completionAfter {
+		after = string(r[:completionAfter])
+	}
+	return fmt.Sprintf(`You are a helpful assistant that completes notes as they are typed.
+Continue the text at <CURSOR> with the most likely next few words.
+Return only the continuation, no other text.
+
+%s<CURSOR>%s`, before, after)
+}
+
+// cursorText splits an editor's content at the cursor.
+func cursorText(ta textarea.Model) (before, after string) {
+	lines := strings.Split(ta.Value(), "\n")
+	row := min(ta.Line(), len(lines)-1)
+	li := ta.LineInfo()
+	line := []rune(lines[row])
+	col := min(li.StartColumn+li.ColumnOffset, len(line))
+
+	before = strings.Join(append(lines[:row:row], string(line[:col])), "\n")
+	after = strings.Join(append([]string{string(line[col:])}, lines[row+1:]...), "\n")
+	return before, after
+}
+
+// suggestionLine is the line below the editor showing the suggestion as
+// ghost text.
+func (m model) suggestionLine(width int) string {
+	var line string
+	switch {
+	case m.suggestionShown():
+		k := keys.Completion
+		line = ui.muted.Faint(true).Render(strings.ReplaceAll(m.suggest.text, "\n", "↵")) +
+			"  " + ui.muted.Render(shortHelp(k.Accept, k.Dismiss))
+	case m.suggest.loading && m.suggest.tabID == m.activeTab().id:
+		line = ui.muted.Render("…")
+	}
+	return lipgloss.NewStyle().Width(width).MaxWidth(width).Render(line)
+}
+
+// updateCompletion handles the keys of a shown suggestion. It reports
+// false for other keys, which dismiss the suggestion.
+func (m *model) updateCompletion(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if !m.suggestionShown() {
+		m.dismissSuggestion()
+		return nil, false
+	}
+	switch {
+	case key.Matches(msg, keys.Completion.Accept):
+		return m.acceptSuggestion(), true
+	case key.Matches(msg, keys.Completion.Dismiss):
+		m.dismissSuggestion()
+		return nil, true
+	}
+	m.dismissSuggestion()
+	return nil, false
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultServer  = "http://localhost:3000"
+	defaultTimeout = 10 * time.Second
+)
+
+// profile describes how to reach one notes-api server.
+type profile struct {
+	URL     string `json:"url"`
+	Token   string `json:"token,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+	TLSCA   string `json:"tls_ca,omitempty"`
+}
+
+// config is read from $XDG_CONFIG_HOME/notes-cli/config.json, e.g.
+//
+//	{
+//	  "default_profile": "work",
+//	  "profiles": {
+//	    "work": {"url": "https://notes.example.com", "token": "…", "timeout": "5s", "tls_ca": "/etc/ssl/work-ca.pem"},
+//	    "local": {"url": "http://localhost:3000"}
+//	  },
+//	  "keymap": {"preset": "vim"},
+//	  "theme": "light",
+//	  "autosave": "2s",
+//	  "completion": {"enabled": true}
+//	}
+//
+// autosave is the pause in typing before a note is saved, or "off".
+type config struct {
+	DefaultProfile string             `json:"default_profile"`
+	Profiles       map[string]profile `json:"profiles"`
+	Keymap         keymapConfig       `json:"keymap"`
+	Theme          string             `json:"theme"`
+	Autosave       string             `json:"autosave"`
+	Completion     completionConfig   `json:"completion"`
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "notes-cli", "config.json"), nil
+}
+
+func loadConfig() (config, error) {
+	var cfg config
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// resolveProfile picks the active profile. Flags take precedence over the
+// NOTES_PROFILE, NOTES_SERVER, NOTES_TOKEN, NOTES_TIMEOUT and NOTES_TLS_CA
+// environment variables, which take precedence over the config file.
+func resolveProfile(cfg config, name, server string) (string, profile, error) {
+	if name == "" {
+		name = os.Getenv("NOTES_PROFILE")
+	}
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+
+	var p profile
+	if name != "" {
+		var ok bool
+		if p, ok = cfg.Profiles[name]; !ok {
+			return name, p, fmt.Errorf("unknown profile %q", name)
+		}
+	} else {
+		name = "default"
+	}
+
+	overrides := []struct {
+		value string
+		field *string
+	}{
+		{os.Getenv("NOTES_SERVER"), &p.URL},
+		{os.Getenv("NOTES_TOKEN"), &p.Token},
+		{os.Getenv("NOTES_TIMEOUT"), &p.Timeout},
+		{os.Getenv("NOTES_TLS_CA"), &p.TLSCA},
+		{server, &p.URL},
+	}
+	for _, o := range overrides {
+		if o.value != "" {
+			*o.field = o.value
+		}
+	}
+	if p.URL == "" {
+		p.URL = defaultServer
+	}
+	return name, p, nil
+}
+
+// api is the client for the active profile, set up by setup.
+var api notesAPI
+
+// apiClient talks to the notes-api server of the active profile.
+type apiClient struct {
+	profile string
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAPIClient(name string, p profile) (*apiClient, error) {
+	timeout := defaultTimeout
+	if p.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(p.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", p.Timeout, err)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if p.TLSCA != "" {
+		pem, err := os.ReadFile(p.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", p.TLSCA)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &apiClient{
+		profile: name,
+		baseURL: strings.TrimSuffix(p.URL, "/"),
+		token:   p.Token,
+		http:    &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// retryDelays are the waits between attempts of a request that could not
+// reach the server.
+var retryDelays = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second}
+
+// do sends a request to path on the server, retrying with backoff when the
+// server cannot be reached. A non-nil body is sent as JSON.
+func (c *apiClient) do(method, path string, body []byte) (*http.Response, error) {
+	return c.request(method, path, nil, body)
+}
+
+// request is do with extra request headers.
+func (c *apiClient) request(method, path string, header http.Header, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(method, path, header, body)
+		if err == nil || attempt == len(retryDelays) || !retryable(method, header, err) {
+			return resp, err
+		}
+		time.Sleep(retryDelays[attempt])
+	}
+}
+
+// retryable reports whether a failed request can safely be sent again.
+// POSTs without an Idempotency-Key are only retried when the connection was
+// never established, since the server may otherwise have created the note
+// already.
+func retryable(method string, header http.Header, err error) bool {
+	if !isNetworkError(err) {
+		return false
+	}
+	if method != http.MethodPost || header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (c *apiClient) send(method, path string, header http.Header, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+func (c *apiClient) get(path string) (*http.Response, error) {
+	return c.do(http.MethodGet, path, nil)
+}
+
+func (c *apiClient) post(path string, body []byte) (*http.Response, error) {
+	return c.do(http.MethodPost, path, body)
+}
+
+// setup parses the command-line flags, configures the API client and loads
+// the keymap, theme, autosave and completion settings. It returns the
+// arguments after the flags, which name a subcommand if there are any.
+func setup(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("notes-cli", flag.ContinueOnError)
+	fs.Usage = func() {
+		printUsage(fs.Output())
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+	profileName := fs.String("profile", "", "config profile to use")
+	server := fs.String("server", "", "notes-api server URL, overriding the profile")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	name, p, err := resolveProfile(cfg, *profileName, *server)
+	if err != nil {
+		return nil, err
+	}
+	if api, err = newAPIClient(name, p); err != nil {
+		return nil, err
+	}
+	if keys, err = loadKeyMap(cfg.Keymap); err != nil {
+		return nil, err
+	}
+	t, err := loadTheme(cfg.Theme)
+	if err != nil {
+		return nil, err
+	}
+	ui = newStyles(t)
+	if autosaveDelay, err = parseAutosave(cfg.Autosave); err != nil {
+		return nil, err
+	}
+	if completion, err = loadCompletion(cfg.Completion); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// resolveConflict applies the user's choice for the first pending conflict.
+func (m model) resolveConflict(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.conflicts[0]
+	var content string
+	keepServer := false
+	switch {
+	case c.locked && !key.Matches(msg, keys.Conflict.KeepServer):
+		return m, nil
+	case c.locked:
+		// A locked edit stays queued and comes back on the next sync.
+		m.conflicts = m.conflicts[1:]
+		return m, m.run(loadNotesCmd(m.view))
+	case key.Matches(msg, keys.Conflict.KeepLocal):
+		content = c.mine
+	case key.Matches(msg, keys.Conflict.KeepServer):
+		keepServer = true
+	case key.Matches(msg, keys.Conflict.KeepBoth):
+		if c.merged == "" {
+			return m, nil
+		}
+		content = c.merged
+	default:
+		return m, nil
+	}
+
+	m.conflicts = m.conflicts[1:]
+	return m, m.run(apiCmd("Resolving conflict", func() error {
+		return resolveNote(c, content, keepServer)
+	}))
+}
+
+// addConflicts queues conflicts for the user, skipping notes that have one
+// pending already; locked conflicts are reported again on every sync.
+func (m *model) addConflicts(conflicts []conflict) {
+next:
+	for _, c := range conflicts {
+		for _, p := range m.conflicts {
+			if p.id == c.id {
+				continue next
+			}
+		}
+		m.conflicts = append(m.conflicts, c)
+	}
+}
+
+func (m model) conflictView() string {
+	c := m.conflicts[0]
+	maxLines := max(m.height-12, 5)
+
+	var lines []string
+	for _, d := range diffLines(c.theirs, c.mine) {
+		switch d.op {
+		case '+':
+			lines = append(lines, ui.ok.Render("+ "+d.text))
+		case '-':
+			lines = append(lines, ui.err.Render("- "+d.text))
+		default:
+			lines = append(lines, ui.muted.Render("  "+d.text))
+		}
+	}
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], ui.muted.Render("…"))
+	}
+
+	k := keys.Conflict
+	help := shortHelp(k.KeepLocal, k.KeepServer)
+	switch {
+	case c.locked:
+		lines = []string{ui.muted.Render("The note is encrypted. Set NOTES_PASSPHRASE to merge your edit;\nuntil then it stays queued.")}
+		help = shortHelp(k.KeepServer)
+	case c.merged != "":
+		help = shortHelp(k.KeepLocal, k.KeepServer, k.KeepBoth)
+	}
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		ui.dialog.Render(lipgloss.JoinVertical(
+			lipgloss.Left,
+			"Sync conflict: "+c.title,
+			"",
+			strings.Join(lines, "\n"),
+			"",
+			ui.muted.Render(help),
+		)),
+	)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encrypted fields are stored on the server as
+// "enc:v2:" + base64(salt | nonce | ciphertext). The key is derived from
+// the passphrase and salt with Argon2id, which is deliberately slow, so a
+// vault seals every field with the same salt and derives its key once; the
+// random 24-byte nonce keeps each encryption unique.
+const encPrefix = "enc:v2:"
+
+// argonTimes holds the Argon2id passes of each format. v2 makes the three
+// passes RFC 9106 recommends at 64 MiB; v1 fields, written with one, can
+// still be read.
+var argonTimes = map[string]uint32{
+	"enc:v1:": 1,
+	encPrefix: 3,
+}
+
+const (
+	saltSize = 16
+	// maxVaultKeys bounds the keys a vault keeps for reading fields sealed
+	// with other salts.
+	maxVaultKeys  = 32
+	argonMemory   = 64 * 1024
+	argonThreads  = 4
+	encryptionKey = chacha20poly1305.KeySize
+)
+
+var errNotEncrypted = errors.New("value is not encrypted")
+
+// vault encrypts note fields with a key derived from a passphrase. Derived
+// keys are cached by salt because Argon2id is deliberately slow.
+type vault struct {
+	passphrase   string
+	encryptTitle bool
+	// salt is the salt of the fields the vault seals. It is random unless
+	// set with useSalt.
+	salt []byte
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func newVault(passphrase string, encryptTitle bool) *vault {
+	salt := make([]byte, saltSize)
+	rand.Read(salt)
+	return &vault{
+		passphrase:   passphrase,
+		encryptTitle: encryptTitle,
+		salt:         salt,
+		keys:         map[string][]byte{},
+	}
+}
+
+// useSalt makes the vault seal fields with the salt stored in path, creating
+// it if there is none, so that notes sealed in every session share one key.
+func (v *vault) useSalt(path string) {
+	salt, err := os.ReadFile(path)
+	if err == nil && len(salt) == saltSize {
+		v.salt = salt
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Printf("Error creating cache directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, v.salt, 0o600); err != nil {
+		log.Printf("Error writing salt: %v", err)
+	}
+}
+
+func (v *vault) key(prefix string, salt []byte) []byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id := prefix + string(salt)
+	if k, ok := v.keys[id]; ok {
+		return k
+	}
+	if len(v.keys) >= maxVaultKeys {
+		clear(v.keys)
+	}
+	k := argon2.IDKey([]byte(v.passphrase), salt, argonTimes[prefix], argonMemory, argonThreads, encryptionKey)
+	v.keys[id] = k
+	return k
+}
+
+// sealedPrefix returns the format prefix of an encrypted value, or "" if it
+// is not encrypted.
+func sealedPrefix(value string) string {
+	for prefix := range argonTimes {
+		if strings.HasPrefix(value, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func (v *vault) encrypt(plaintext string) (string, error) {
+	aead, err := chacha20poly1305.NewX(v.key(encPrefix, v.salt))
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := make([]byte, 0, saltSize+len(nonce)+len(plaintext)+chacha20poly1305.Overhead)
+	out = append(out, v.salt...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, []byte(plaintext), []byte(encPrefix))
+	return encPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+func (v *vault) decrypt(value string) (string, error) {
+	prefix := sealedPrefix(value)
+	if prefix == "" {
+		return "", errNotEncrypted
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	if len(raw) < saltSize+chacha20poly1305.NonceSizeX {
+		return "", errors.New("invalid ciphertext: too short")
+	}
+
+	salt, rest := raw[:saltSize], raw[saltSize:]
+	nonce, ciphertext := rest[:chacha20poly1305.NonceSizeX], rest[chacha20poly1305.NonceSizeX:]
+	aead, err := chacha20poly1305.NewX(v.key(prefix, salt))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(prefix))
+	if err != nil {
+		return "", errors.New("wrong passphrase or corrupted note")
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts a note's content, and its title if configured, before it is
+// sent to the server.
+func (v *vault) seal(note *apiNote) error {
+	var err error
+	if note.Content, err = v.encrypt(note.Content); err != nil {
+		return err
+	}
+	if v.encryptTitle {
+		if note.Title, err = v.encrypt(note.Title); err != nil {
+			return err
+		}
+	}
+	note.Encrypted = true
+	return nil
+}
+
+// open decrypts a note received from the server. Titles are only decrypted
+// when they carry the ciphertext prefix.
+func (v *vault) open(note *apiNote) error {
+	var err error
+	if note.Content, err = v.decrypt(note.Content); err != nil {
+		return err
+	}
+	if sealedPrefix(note.Title) != "" {
+		if note.Title, err = v.decrypt(note.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openNote decrypts an encrypted note in place. It reports false when the
+// note stays locked because no passphrase is configured or it is wrong.
+func openNote(note *apiNote) bool {
+	if !note.Encrypted {
+		return true
+	}
+	if notesVault != nil {
+		err := notesVault.open(note)
+		if err == nil {
+			return true
+		}
+		log.Printf("Error decrypting note %s: %v", note.ID, err)
+	}
+	if sealedPrefix(note.Title) != "" {
+		note.Title = "Encrypted note"
+	}
+	note.Content = ""
+	return false
+}
+
+// sealNote encrypts a note before it is sent when a passphrase is configured.
+func sealNote(note *apiNote) error {
+	if notesVault == nil {
+		return nil
+	}
+	return notesVault.seal(note)
+}
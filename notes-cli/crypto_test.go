@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	v := newVault("correct horse", true)
+	note := apiNote{Title: "Diary", Content: "Dear diary,\nnothing happened."}
+	if err := v.seal(&note); err != nil {
+		t.Fatal(err)
+	}
+	if !note.Encrypted || !strings.HasPrefix(note.Title, encPrefix) || !strings.HasPrefix(note.Content, encPrefix) {
+		t.Fatalf("sealed %+v", note)
+	}
+	if strings.Contains(note.Content, "diary") {
+		t.Error("plaintext visible in the ciphertext")
+	}
+
+	// A vault with the same passphrase, as in the next session, opens it.
+	if err := newVault("correct horse", false).open(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Title != "Diary" || note.Content != "Dear diary,\nnothing happened." {
+		t.Errorf("opened %+v", note)
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	sealed, err := newVault("right", false).encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newVault("wrong", false).decrypt(sealed); err == nil {
+		t.Error("decrypted with the wrong passphrase")
+	}
+}
+
+func TestVaultTampered(t *testing.T) {
+	v := newVault("right", false)
+	sealed, err := v.encrypt("pay Bo 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, encPrefix))
+	raw[len(raw)-1] ^= 1
+	for _, value := range []string{
+		encPrefix + base64.StdEncoding.EncodeToString(raw),
+		encPrefix + "not base64!",
+		encPrefix + base64.StdEncoding.EncodeToString(raw[:10]),
+		// The format version is authenticated too.
+		"enc:v1:" + strings.TrimPrefix(sealed, encPrefix),
+	} {
+		if _, err := v.decrypt(value); err == nil {
+			t.Errorf("decrypted tampered value %q", value)
+		}
+	}
+}
+
+func TestVaultPlaintext(t *testing.T) {
+	v := newVault("right", false)
+	if _, err := v.decrypt("just text"); !errors.Is(err, errNotEncrypted) {
+		t.Errorf("decrypt of plaintext: %v", err)
+	}
+
+	// Titles are only encrypted on request, so a plain title passes through.
+	content, err := v.encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	note := apiNote{Title: "Diary", Content: content, Encrypted: true}
+	if err := v.open(&note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Title != "Diary" || note.Content != "secret" {
+		t.Errorf("opened %+v", note)
+	}
+}
+
+func TestVaultReadsV1(t *testing.T) {
+	// v1 values were sealed the same way with one Argon2id pass.
+	v := newVault("right", false)
+	salt, nonce := make([]byte, saltSize), make([]byte, chacha20poly1305.NonceSizeX)
+	aead, err := chacha20poly1305.NewX(argon2.IDKey([]byte("right"), salt, 1, argonMemory, argonThreads, encryptionKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := aead.Seal(append(salt, nonce...), nonce, []byte("old note"), []byte("enc:v1:"))
+	v1 := "enc:v1:" + base64.StdEncoding.EncodeToString(raw)
+	if got, err := v.decrypt(v1); err != nil || got != "old note" {
+		t.Errorf("decrypt v1 = %q, %v", got, err)
+	}
+}
+
+func TestVaultDerivesKeyOnce(t *testing.T) {
+	// Vaults sharing a stored salt, as sessions do, seal every field with
+	// one key, so reading and writing notes derives it once.
+	path := filepath.Join(t.TempDir(), "vault.salt")
+	first, second := newVault("right", true), newVault("right", true)
+	first.useSalt(path)
+	second.useSalt(path)
+
+	var notes []apiNote
+	for _, content := range []string{"one", "two", "three"} {
+		note := apiNote{Title: "Diary", Content: content}
+		if err := first.seal(&note); err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, note)
+	}
+	for _, note := range notes {
+		if err := second.open(&note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(first.keys) != 1 || len(second.keys) != 1 {
+		t.Errorf("derived %d and %d keys, want 1 each", len(first.keys), len(second.keys))
+	}
+
+	// The nonce still makes every encryption differ.
+	a, _ := first.encrypt("same")
+	b, _ := first.encrypt("same")
+	if a == b {
+		t.Error("the same plaintext sealed twice gave the same ciphertext")
+	}
+}
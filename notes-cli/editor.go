@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorDoneMsg reports that the external editor exited. content is the
+// edited file, read back before the temp file was removed.
+type editorDoneMsg struct {
+	item    noteListItem
+	content string
+	err     error
+}
+
+// editorSavedMsg reports the save after an external edit. If the server copy
+// changed while the editor was open, the edit is merged into it, or handed
+// over as a conflict when the two overlap.
+type editorSavedMsg struct {
+	notice    string
+	warn      bool
+	conflicts []conflict
+	err       error
+}
+
+// editorCommand builds the command for $VISUAL or $EDITOR, falling back to
+// vi. The variables may carry arguments, as in EDITOR="code --wait".
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// openEditor suspends the program and opens the note's content in the
+// user's editor. The temp file is only readable by the user, as it holds
+// decrypted content for encrypted notes.
+func openEditor(item noteListItem) tea.Cmd {
+	path, err := writeTempNote(item.content)
+	if err != nil {
+		return func() tea.Msg { return editorDoneMsg{item: item, err: err} }
+	}
+
+	return tea.ExecProcess(editorCommand(path), func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return editorDoneMsg{item: item, err: err}
+		}
+		data, err := os.ReadFile(path)
+		return editorDoneMsg{item: item, content: string(data), err: err}
+	})
+}
+
+// writeTempNote writes content to a new temp file for the editor and
+// returns its path.
+func writeTempNote(content string) (string, error) {
+	f, err := os.CreateTemp("", "note-*.md")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (m *model) handleEditorDone(msg editorDoneMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.notify(fmt.Sprintf("Editor failed: %v", msg.err), true)
+	}
+	content := msg.content
+	// Most editors end the file with a newline the note did not have.
+	if !strings.HasSuffix(msg.item.content, "\n") {
+		content = strings.TrimSuffix(content, "\n")
+	}
+	if content == msg.item.content {
+		return m.notify("No changes", false)
+	}
+	m.selectID = msg.item.id
+	return m.run(saveEditedCmd(msg.item, content))
+}
+
+// saveEditedCmd saves an external edit. When the server copy was updated
+// after the note was loaded, the edit is merged three-way like an offline
+// change.
+func saveEditedCmd(item noteListItem, content string) tea.Cmd {
+	return func() tea.Msg {
+		added, removed := 0, 0
+		for _, d := range diffLines(item.content, content) {
+			switch d.op {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+		msg := editorSavedMsg{notice: fmt.Sprintf("Saved %q (+%d −%d lines)", item.title, added, removed)}
+
+		title := item.title
+		if !strings.HasPrefix(item.id, localIDPrefix) {
+			server, changed, err := fetchIfChanged(item.id, item.updatedAt)
+			if err != nil && !isNetworkError(err) {
+				msg.err = err
+				return msg
+			}
+			if changed {
+				msg.warn = true
+				wire := server
+				if !openNote(&server) {
+					// Neither a merge nor a choice between the copies is
+					// possible, so the edit is not saved but kept in a file
+					// only the user can read.
+					path, err := writeTempNote(content)
+					if err != nil {
+						msg.err = fmt.Errorf("server copy changed while editing and could not be decrypted; your edit was lost: %w", err)
+						return msg
+					}
+					msg.notice = fmt.Sprintf("Server copy changed while editing and could not be decrypted; not saved, your edit is in %s", path)
+					return msg
+				}
+				merged, clean := merge3(item.content, content, server.Content)
+				if !clean {
+					msg.notice = "Server copy changed while editing; choose which to keep"
+					msg.conflicts = []conflict{{id: item.id, title: title, mine: content, theirs: server.Content, merged: merged, server: wire}}
+					return msg
+				}
+				msg.notice = "Server copy changed while editing; your changes were merged into it"
+				title, content = server.Title, merged
+			}
+		}
+
+		msg.err = updateNote(item.id, title, content)
+		return msg
+	}
+}
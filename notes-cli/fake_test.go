@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// epoch is the fake server's clock at start. Every write moves it on by a
+// minute, so timestamps in views are the same on every run.
+var epoch = time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC)
+
+// fakeServer is an in-memory notes-api for tests. IDs are assigned in
+// order as note-1, note-2, …
+type fakeServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	notes map[string]apiNote
+	next  int
+	clock time.Time
+	// created maps Idempotency-Keys to the notes they created.
+	created map[string]apiNote
+	// lost is the number of creates still to go whose response is dropped,
+	// as if the connection broke after the server stored the note.
+	lost int
+	// refuse, if not 0, is the status answered to every write instead of
+	// storing it.
+	refuse int
+	// stall, if set, is sent a channel by every list request, which then
+	// waits for the channel to be closed.
+	stall chan chan struct{}
+}
+
+// newFakeServer starts a fake server holding notes, which get IDs and
+// timestamps if they have none.
+func newFakeServer(t *testing.T, notes ...apiNote) *fakeServer {
+	t.Helper()
+	s := &fakeServer{notes: map[string]apiNote{}, created: map[string]apiNote{}, clock: epoch}
+	for _, n := range notes {
+		s.create(n)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /notes", s.list)
+	mux.HandleFunc("GET /notes/{id}", s.get)
+	mux.HandleFunc("POST /notes", s.refusing(s.post))
+	mux.HandleFunc("PUT /notes/{id}", s.refusing(s.put))
+	mux.HandleFunc("DELETE /notes/{id}", s.refusing(s.delete))
+	mux.HandleFunc("POST /notes/{id}/{action}", s.refusing(s.action))
+	mux.HandleFunc("GET /search", s.search)
+	mux.HandleFunc("GET /templates", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []apiTemplate{})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) tick() time.Time {
+	s.clock = s.clock.Add(time.Minute)
+	return s.clock
+}
+
+func (s *fakeServer) create(n apiNote) apiNote {
+	s.next++
+	if n.ID == "" {
+		n.ID = fmt.Sprintf("note-%d", s.next)
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = s.tick()
+	}
+	if n.UpdatedAt.IsZero() {
+		n.UpdatedAt = n.CreatedAt
+	}
+	s.notes[n.ID] = n
+	return n
+}
+
+// note returns a stored note, for checking what the UI sent.
+func (s *fakeServer) note(id string) (apiNote, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notes[id]
+	return n, ok
+}
+
+// count returns the number of stored notes.
+func (s *fakeServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.notes)
+}
+
+// sorted lists notes as the real server does, pinned first and then newest
+// first.
+func (s *fakeServer) sorted(keep func(apiNote) bool) []apiNote {
+	notes := []apiNote{}
+	for _, n := range s.notes {
+		if keep(n) {
+			notes = append(notes, n)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].Pinned != notes[j].Pinned {
+			return notes[i].Pinned
+		}
+		return notes[i].CreatedAt.After(notes[j].CreatedAt)
+	})
+	return notes
+}
+
+func (s *fakeServer) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stall := s.stall
+	s.mu.Unlock()
+	if stall != nil {
+		wait := make(chan struct{})
+		stall <- wait
+		<-wait
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := r.URL.Query()
+	if since := q.Get("updated_since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			http.Error(w, "Invalid updated_since, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.sorted(func(n apiNote) bool { return n.UpdatedAt.After(t) }))
+		return
+	}
+	archived, favorite := q.Get("archived") == "true", q.Get("favorite") == "true"
+	writeJSON(w, http.StatusOK, s.sorted(func(n apiNote) bool {
+		return n.Archived == archived && (!favorite || n.Favorite)
+	}))
+}
+
+func (s *fakeServer) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notes[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *fakeServer) post(w http.ResponseWriter, r *http.Request) {
+	var n apiNote
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, "Invalid note", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Header.Get("Idempotency-Key")
+	if created, ok := s.created[key]; ok && key != "" {
+		n = created
+	} else {
+		n.ID, n.CreatedAt, n.UpdatedAt = "", time.Time{}, time.Time{}
+		n = s.create(n)
+		if key != "" {
+			s.created[key] = n
+		}
+	}
+	if s.lost > 0 {
+		s.lost--
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	w.Header().Set("Location", "/notes/"+n.ID)
+	writeJSON(w, http.StatusCreated, n)
+}
+
+// refusing answers writes with s.refuse while it is set.
+func (s *fakeServer) refusing(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status := s.refuse
+		s.mu.Unlock()
+		if status != 0 {
+			http.Error(w, "Refused", status)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// setRefuse makes the server answer writes with status, or accept them
+// again if it is 0.
+func (s *fakeServer) setRefuse(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = status
+}
+
+// stallLists makes list requests wait until they are let go. Each request
+// arriving sends a channel on the returned one; closing it lets it go.
+func (s *fakeServer) stallLists() <-chan chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stall = make(chan chan struct{})
+	return s.stall
+}
+
+// loseCreates drops the responses of the next n creates.
+func (s *fakeServer) loseCreates(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lost = n
+}
+
+func (s *fakeServer) put(w http.ResponseWriter, r *http.Request) {
+	var n apiNote
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, "Invalid note", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.notes[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	n.ID, n.CreatedAt, n.UpdatedAt = old.ID, old.CreatedAt, s.tick()
+	s.notes[n.ID] = n
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *fakeServer) delete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := s.notes[id]; !ok {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	delete(s.notes, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// action handles pin, archive, favorite, tags and summarize.
+func (s *fakeServer) action(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notes[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	switch r.PathValue("action") {
+	case "pin":
+		n.Pinned = !n.Pinned
+	case "archive":
+		n.Archived = !n.Archived
+	case "favorite":
+		n.Favorite = !n.Favorite
+	case "tags":
+		var req struct{ Add, Remove []string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid tags", http.StatusBadRequest)
+			return
+		}
+		tags := map[string]bool{}
+		for _, tag := range append(n.Tags, req.Add...) {
+			tags[tag] = true
+		}
+		for _, tag := range req.Remove {
+			delete(tags, tag)
+		}
+		n.Tags = nil
+		for tag := range tags {
+			n.Tags = append(n.Tags, tag)
+		}
+		sort.Strings(n.Tags)
+	case "summarize":
+		n.Summary, _, _ = strings.Cut(n.Content, "\n")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	n.UpdatedAt = s.tick()
+	s.notes[n.ID] = n
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *fakeServer) search(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := strings.ToLower(r.URL.Query().Get("q"))
+	writeJSON(w, http.StatusOK, s.sorted(func(n apiNote) bool {
+		return !n.Archived && strings.Contains(strings.ToLower(n.Title+"\n"+n.Content), q)
+	}))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -16,13 +16,23 @@ require (
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
 	github.com/muesli/cancelreader v0.2.2 // indirect
-	github.com/muesli/termenv v0.15.2 // indirect
+	github.com/muesli/termenv v0.15.2
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sync v0.9.0 // indirect
-	golang.org/x/sys v0.27.0 // indirect
-	golang.org/x/text v0.3.8 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
 
-require github.com/sahilm/fuzzy v0.1.1 // indirect
+require github.com/sahilm/fuzzy v0.1.1
 
-require github.com/atotto/clipboard v0.1.4 // indirect
+require (
+	github.com/BurntSushi/toml v1.4.0
+	golang.org/x/crypto v0.32.0
+)
+
+require (
+	autocomplete v0.0.0
+	github.com/atotto/clipboard v0.1.4 // indirect
+)
+
+replace autocomplete => ../autocomplete
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testAPI is a client of a fake server. It shows a fixed URL, so views do
+// not depend on the server's port.
+type testAPI struct{ *apiClient }
+
+func (testAPI) serverURL() string { return "http://notes.test" }
+
+// tui drives a model the way a tea.Program would, but synchronously: each
+// message is handled and the commands it returns are run to completion
+// before the next key is sent.
+type tui struct {
+	t    *testing.T
+	m    model
+	quit bool
+}
+
+// newTUI starts the UI against srv in an 80×24 terminal, with timers off
+// and a fresh cache and config directory.
+func newTUI(t *testing.T, srv *fakeServer) *tui {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	c, err := newAPIClient("test", profile{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, err := loadTheme("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every test sets the globals it needs; only the timers are put back.
+	t.Cleanup(func() { after = tea.Tick })
+	api, keys, ui = testAPI{c}, defaultKeyMap(), newStyles(th)
+	autosaveDelay, completion, hyperlinks, notesVault = 0, completionSettings{}, false, nil
+	after = func(time.Duration, func(time.Time) tea.Msg) tea.Cmd { return nil }
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	tm := &tui{t: t, m: initialModel()}
+	tm.run(tm.m.Init())
+	tm.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return tm
+}
+
+// send handles msg and everything its commands lead to.
+func (tm *tui) send(msg tea.Msg) {
+	tm.t.Helper()
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		msg, queue = queue[0], queue[1:]
+		if _, ok := msg.(tea.QuitMsg); ok {
+			tm.quit = true
+			continue
+		}
+		next, cmd := tm.m.Update(msg)
+		tm.m = next.(model)
+		queue = append(queue, collect(cmd)...)
+	}
+}
+
+func (tm *tui) run(cmd tea.Cmd) {
+	tm.t.Helper()
+	for _, msg := range collect(cmd) {
+		tm.send(msg)
+	}
+}
+
+// collect runs cmd and the commands of any batch it returns. Spinner
+// frames and cursor blinks only animate the view and are dropped; blinks
+// before they run, as they wait for the blink interval.
+func collect(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil || isBubbles(runtime.FuncForPC(reflect.ValueOf(cmd).Pointer()).Name(), "cursor") {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, collect(c)...)
+		}
+		return msgs
+	}
+	if msg == nil {
+		return nil
+	}
+	if pkg := reflect.TypeOf(msg).PkgPath(); isBubbles(pkg, "spinner") || isBubbles(pkg, "cursor") {
+		return nil
+	}
+	return []tea.Msg{msg}
+}
+
+// isBubbles reports whether a package path or function name belongs to
+// the given bubbles package.
+func isBubbles(name, pkg string) bool {
+	return strings.Contains(name, "/bubbles/"+pkg+".") || strings.HasSuffix(name, "/bubbles/"+pkg)
+}
+
+// keyTypes are the named keys press understands, besides ctrl+<letter>.
+var keyTypes = map[string]tea.KeyType{
+	"enter":     tea.KeyEnter,
+	"esc":       tea.KeyEsc,
+	"tab":       tea.KeyTab,
+	"shift+tab": tea.KeyShiftTab,
+	"backspace": tea.KeyBackspace,
+	"up":        tea.KeyUp,
+	"down":      tea.KeyDown,
+	"left":      tea.KeyLeft,
+	"right":     tea.KeyRight,
+	"home":      tea.KeyHome,
+	"end":       tea.KeyEnd,
+}
+
+// press sends keys by name, as in "enter", "ctrl+n", "alt+z" or "d".
+func (tm *tui) press(names ...string) {
+	tm.t.Helper()
+	for _, name := range names {
+		var k tea.Key
+		if rest, ok := strings.CutPrefix(name, "alt+"); ok {
+			k.Alt, name = true, rest
+		}
+		switch t, ok := keyTypes[name]; {
+		case ok:
+			k.Type = t
+		case name == "space":
+			k.Type, k.Runes = tea.KeySpace, []rune{' '}
+		case strings.HasPrefix(name, "ctrl+") && len(name) == len("ctrl+")+1:
+			k.Type = tea.KeyCtrlA + tea.KeyType(name[len(name)-1]-'a')
+		case len([]rune(name)) == 1:
+			k.Type, k.Runes = tea.KeyRunes, []rune(name)
+		default:
+			tm.t.Fatalf("unknown key %q", name)
+		}
+		tm.send(tea.KeyMsg(k))
+	}
+}
+
+// typeText types text a rune at a time.
+func (tm *tui) typeText(text string) {
+	tm.t.Helper()
+	for _, r := range text {
+		tm.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// golden compares the view with testdata/<name>.golden, or rewrites the
+// file when the tests run with -update.
+func (tm *tui) golden(name string) {
+	tm.t.Helper()
+	got := tm.m.View() + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			tm.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			tm.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tm.t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		tm.t.Errorf("view does not match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// keys is the active keymap, set up by setup.
+var keys = defaultKeyMap()
+
+// keymapConfig is the "keymap" section of the config file, e.g.
+//
+//	"keymap": {
+//	  "preset": "vim",
+//	  "bindings": {"list.delete": ["x"], "editor.save": ["ctrl+s"]}
+//	}
+//
+// Bindings are named scope.action, as listed in the help overlay, and
+// replace the keys of the preset. NOTES_KEYMAP overrides the preset.
+type keymapConfig struct {
+	Preset   string              `json:"preset,omitempty"`
+	Bindings map[string][]string `json:"bindings,omitempty"`
+}
+
+type globalKeys struct {
+	Quit key.Binding
+}
+
+type listKeys struct {
+	Up, Down, Top, Bottom   key.Binding
+	Open, New, Delete       key.Binding
+	Rename, Duplicate, Edit key.Binding
+	Pin, Archive, Favorite  key.Binding
+	View, Summarize         key.Binding
+	Search, Calendar, Help  key.Binding
+	Shrink, Grow, Zen       key.Binding
+	OpenTab, NextTab        key.Binding
+	PrevTab, CloseTab       key.Binding
+	Split, SaveAll          key.Binding
+	Mark, ClearMarks        key.Binding
+	Sort, Group             key.Binding
+	Tag, Export             key.Binding
+	OpenLink                key.Binding
+}
+
+type editorKeys struct {
+	Save, Back, Zen     key.Binding
+	NextTab, PrevTab    key.Binding
+	CloseTab, OtherPane key.Binding
+	SaveAll, OpenLink   key.Binding
+}
+
+type completionKeys struct {
+	Accept, Dismiss key.Binding
+}
+
+type dialogKeys struct {
+	Confirm, Cancel            key.Binding
+	NextTemplate, PrevTemplate key.Binding
+}
+
+type confirmKeys struct {
+	Yes, No key.Binding
+}
+
+type unsavedKeys struct {
+	Save, Discard, Cancel key.Binding
+}
+
+type searchKeys struct {
+	Up, Down, Open, Cancel key.Binding
+}
+
+type calendarKeys struct {
+	Left, Right, Up, Down key.Binding
+	PrevMonth, NextMonth  key.Binding
+	Open, Back            key.Binding
+}
+
+type conflictKeys struct {
+	KeepLocal, KeepServer, KeepBoth key.Binding
+}
+
+// keyMap holds the bindings of each focus state. Bindings are only matched
+// in their own scope, so the same key can mean different things in the list
+// and in a dialog, and never fires while text is being typed.
+type keyMap struct {
+	Global     globalKeys
+	List       listKeys
+	Editor     editorKeys
+	Completion completionKeys
+	Dialog     dialogKeys
+	Confirm    confirmKeys
+	Unsaved    unsavedKeys
+	Search     searchKeys
+	Calendar   calendarKeys
+	Conflict   conflictKeys
+}
+
+func binding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keyCodes(keys)...), key.WithHelp(strings.Join(keys, "/"), desc))
+}
+
+// keyCodes maps key names to the strings key messages report, which differ
+// for the space bar.
+func keyCodes(keys []string) []string {
+	codes := make([]string, len(keys))
+	for i, k := range keys {
+		if k == "space" {
+			k = " "
+		}
+		codes[i] = k
+	}
+	return codes
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Global: globalKeys{
+			Quit: binding("quit", "ctrl+c"),
+		},
+		List: listKeys{
+			Up:         binding("previous note", "up", "k"),
+			Down:       binding("next note", "down", "j"),
+			Top:        binding("first note", "home", "g"),
+			Bottom:     binding("last note", "end", "G"),
+			Open:       binding("edit note", "enter"),
+			New:        binding("new note", "ctrl+n"),
+			Delete:     binding("delete note", "d"),
+			Rename:     binding("rename note", "r"),
+			Duplicate:  binding("duplicate note", "y"),
+			Edit:       binding("open in $EDITOR", "e"),
+			Pin:        binding("pin note", "p"),
+			Archive:    binding("archive note", "a"),
+			Favorite:   binding("favorite note", "*"),
+			View:       binding("switch view", "v"),
+			Summarize:  binding("summarize note", "s"),
+			Search:     binding("search notes", "/"),
+			Calendar:   binding("daily notes calendar", "c"),
+			Help:       binding("toggle help", "?"),
+			Shrink:     binding("narrow the list", "<"),
+			Grow:       binding("widen the list", ">"),
+			Zen:        binding("fullscreen editor", "z"),
+			OpenTab:    binding("open in new tab", "t"),
+			NextTab:    binding("next tab", "tab"),
+			PrevTab:    binding("previous tab", "shift+tab"),
+			CloseTab:   binding("close tab", "w"),
+			Split:      binding("split editor", "|"),
+			SaveAll:    binding("save all tabs", "S"),
+			Mark:       binding("select note", "space"),
+			ClearMarks: binding("clear selection", "esc"),
+			Sort:       binding("change sort order", "O"),
+			Group:      binding("change grouping", "#"),
+			Tag:        binding("tag notes", "T"),
+			Export:     binding("export notes", "X"),
+			OpenLink:   binding("open link", "L"),
+		},
+		Editor: editorKeys{
+			Save:      binding("save and go back", "ctrl+b"),
+			Back:      binding("back to list", "esc"),
+			Zen:       binding("toggle fullscreen", "alt+z"),
+			NextTab:   binding("next tab", "alt+]"),
+			PrevTab:   binding("previous tab", "alt+["),
+			CloseTab:  binding("close tab", "alt+w"),
+			OtherPane: binding("other split pane", "alt+o"),
+			SaveAll:   binding("save all tabs", "alt+s"),
+			OpenLink:  binding("open link on this line", "alt+l"),
+		},
+		Completion: completionKeys{
+			Accept:  binding("accept suggestion", "tab"),
+			Dismiss: binding("dismiss suggestion", "esc"),
+		},
+		Dialog: dialogKeys{
+			Confirm:      binding("confirm", "enter"),
+			Cancel:       binding("cancel", "esc"),
+			NextTemplate: binding("next template", "tab"),
+			PrevTemplate: binding("previous template", "shift+tab"),
+		},
+		Confirm: confirmKeys{
+			Yes: binding("delete", "y", "enter"),
+			No:  binding("cancel", "n", "esc"),
+		},
+		Unsaved: unsavedKeys{
+			Save:    binding("save", "s", "enter"),
+			Discard: binding("discard changes", "d"),
+			Cancel:  binding("cancel", "esc"),
+		},
+		Search: searchKeys{
+			Up:     binding("previous match", "up", "ctrl+p"),
+			Down:   binding("next match", "down", "ctrl+n"),
+			Open:   binding("open match", "enter"),
+			Cancel: binding("cancel", "esc"),
+		},
+		Calendar: calendarKeys{
+			Left:      binding("previous day", "left", "h"),
+			Right:     binding("next day", "right", "l"),
+			Up:        binding("previous week", "up", "k"),
+			Down:      binding("next week", "down", "j"),
+			PrevMonth: binding("previous month", "["),
+			NextMonth: binding("next month", "]"),
+			Open:      binding("open daily note", "enter"),
+			Back:      binding("back to list", "esc", "c"),
+		},
+		Conflict: conflictKeys{
+			KeepLocal:  binding("keep local (+)", "1"),
+			KeepServer: binding("keep server (-)", "2"),
+			KeepBoth:   binding("keep both with conflict markers", "3"),
+		},
+	}
+}
+
+// keyPresets replace bindings of the default keymap.
+var keyPresets = map[string]map[string][]string{
+	"default": {},
+	"vim": {
+		"list.open":     {"enter", "l", "i"},
+		"list.new":      {"o"},
+		"list.delete":   {"x", "d"},
+		"editor.save":   {"ctrl+s"},
+		"search.up":     {"up", "ctrl+k"},
+		"search.down":   {"down", "ctrl+j"},
+		"calendar.open": {"enter", "o"},
+	},
+	"emacs": {
+		"list.up":            {"up", "ctrl+p"},
+		"list.down":          {"down", "ctrl+n"},
+		"list.top":           {"home", "alt+<"},
+		"list.bottom":        {"end", "alt+>"},
+		"list.new":           {"alt+n"},
+		"list.delete":        {"ctrl+d"},
+		"list.search":        {"ctrl+s"},
+		"editor.save":        {"ctrl+s"},
+		"editor.back":        {"esc", "ctrl+g"},
+		"completion.dismiss": {"esc", "ctrl+g"},
+		"dialog.cancel":      {"esc", "ctrl+g"},
+		"confirm.no":         {"n", "esc", "ctrl+g"},
+		"unsaved.cancel":     {"esc", "ctrl+g"},
+		"search.cancel":      {"esc", "ctrl+g"},
+		"calendar.left":      {"left", "ctrl+b"},
+		"calendar.right":     {"right", "ctrl+f"},
+		"calendar.up":        {"up", "ctrl+p"},
+		"calendar.down":      {"down", "ctrl+n"},
+		"calendar.back":      {"esc", "ctrl+g"},
+	},
+}
+
+// keyScope is one focus state's bindings, in help order.
+type keyScope struct {
+	name, title string
+	bindings    []namedBinding
+}
+
+type namedBinding struct {
+	name    string
+	binding *key.Binding
+}
+
+func (k *keyMap) scopes() []keyScope {
+	return []keyScope{
+		{"global", "Global", []namedBinding{
+			{"quit", &k.Global.Quit},
+		}},
+		{"list", "Note list", []namedBinding{
+			{"up", &k.List.Up}, {"down", &k.List.Down}, {"top", &k.List.Top}, {"bottom", &k.List.Bottom},
+			{"open", &k.List.Open}, {"new", &k.List.New}, {"delete", &k.List.Delete},
+			{"rename", &k.List.Rename}, {"duplicate", &k.List.Duplicate}, {"edit", &k.List.Edit},
+			{"pin", &k.List.Pin}, {"archive", &k.List.Archive}, {"favorite", &k.List.Favorite},
+			{"view", &k.List.View}, {"summarize", &k.List.Summarize},
+			{"search", &k.List.Search}, {"calendar", &k.List.Calendar}, {"help", &k.List.Help},
+			{"shrink", &k.List.Shrink}, {"grow", &k.List.Grow}, {"zen", &k.List.Zen},
+			{"open_tab", &k.List.OpenTab}, {"next_tab", &k.List.NextTab}, {"prev_tab", &k.List.PrevTab},
+			{"close_tab", &k.List.CloseTab}, {"split", &k.List.Split}, {"save_all", &k.List.SaveAll},
+			{"mark", &k.List.Mark}, {"clear_marks", &k.List.ClearMarks},
+			{"sort", &k.List.Sort}, {"group", &k.List.Group}, {"tag", &k.List.Tag}, {"export", &k.List.Export},
+			{"open_link", &k.List.OpenLink},
+		}},
+		{"editor", "Editor", []namedBinding{
+			{"save", &k.Editor.Save}, {"back", &k.Editor.Back}, {"zen", &k.Editor.Zen},
+			{"next_tab", &k.Editor.NextTab}, {"prev_tab", &k.Editor.PrevTab}, {"close_tab", &k.Editor.CloseTab},
+			{"other_pane", &k.Editor.OtherPane}, {"save_all", &k.Editor.SaveAll}, {"open_link", &k.Editor.OpenLink},
+		}},
+		{"completion", "Inline completion", []namedBinding{
+			{"accept", &k.Completion.Accept}, {"dismiss", &k.Completion.Dismiss},
+		}},
+		{"dialog", "New note and rename", []namedBinding{
+			{"confirm", &k.Dialog.Confirm}, {"cancel", &k.Dialog.Cancel},
+			{"next_template", &k.Dialog.NextTemplate}, {"prev_template", &k.Dialog.PrevTemplate},
+		}},
+		{"confirm", "Delete confirmation", []namedBinding{
+			{"yes", &k.Confirm.Yes}, {"no", &k.Confirm.No},
+		}},
+		{"unsaved", "Unsaved changes", []namedBinding{
+			{"save", &k.Unsaved.Save}, {"discard", &k.Unsaved.Discard}, {"cancel", &k.Unsaved.Cancel},
+		}},
+		{"search", "Search", []namedBinding{
+			{"up", &k.Search.Up}, {"down", &k.Search.Down}, {"open", &k.Search.Open}, {"cancel", &k.Search.Cancel},
+		}},
+		{"calendar", "Calendar", []namedBinding{
+			{"left", &k.Calendar.Left}, {"right", &k.Calendar.Right}, {"up", &k.Calendar.Up}, {"down", &k.Calendar.Down},
+			{"prev_month", &k.Calendar.PrevMonth}, {"next_month", &k.Calendar.NextMonth},
+			{"open", &k.Calendar.Open}, {"back", &k.Calendar.Back},
+		}},
+		{"conflict", "Sync conflict", []namedBinding{
+			{"keep_local", &k.Conflict.KeepLocal}, {"keep_server", &k.Conflict.KeepServer}, {"keep_both", &k.Conflict.KeepBoth},
+		}},
+	}
+}
+
+// loadKeyMap builds the keymap from a preset and the bindings of the config
+// file. It fails on unknown names and on keys bound twice in one scope.
+func loadKeyMap(cfg keymapConfig) (keyMap, error) {
+	k := defaultKeyMap()
+
+	preset := cfg.Preset
+	if env := os.Getenv("NOTES_KEYMAP"); env != "" {
+		preset = env
+	}
+	if preset == "" {
+		preset = "default"
+	}
+	overrides, ok := keyPresets[preset]
+	if !ok {
+		return k, fmt.Errorf("unknown keymap preset %q, want one of %s", preset, strings.Join(presetNames(), ", "))
+	}
+	if err := k.apply(overrides); err != nil {
+		return k, err
+	}
+	if err := k.apply(cfg.Bindings); err != nil {
+		return k, err
+	}
+	return k, k.checkCollisions()
+}
+
+func (k *keyMap) apply(bindings map[string][]string) error {
+	byName := map[string]*key.Binding{}
+	for _, s := range k.scopes() {
+		for _, b := range s.bindings {
+			byName[s.name+"."+b.name] = b.binding
+		}
+	}
+	for name, keys := range bindings {
+		b, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("unknown key binding %q", name)
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("key binding %q has no keys", name)
+		}
+		b.SetKeys(keyCodes(keys)...)
+		b.SetHelp(strings.Join(keys, "/"), b.Help().Desc)
+	}
+	return nil
+}
+
+// checkCollisions makes sure no key is bound twice within a scope, or in a
+// scope and globally.
+func (k *keyMap) checkCollisions() error {
+	scopes := k.scopes()
+	global := scopes[0]
+	for _, s := range scopes[1:] {
+		seen := map[string]string{}
+		for _, scope := range []keyScope{global, s} {
+			for _, b := range scope.bindings {
+				name := scope.name + "." + b.name
+				for _, key := range b.binding.Keys() {
+					if other, ok := seen[key]; ok {
+						return fmt.Errorf("key %q is bound to both %s and %s", key, other, name)
+					}
+					seen[key] = name
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// shortHelp renders bindings as a one-line hint, as shown in dialogs.
+func shortHelp(bindings ...key.Binding) string {
+	var parts []string
+	for _, b := range bindings {
+		parts = append(parts, b.Help().Key+" "+b.Help().Desc)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// helpView lists every binding, grouped by scope and laid out in as many
+// columns as fit the window.
+func (m model) helpView() string {
+	var sections []string
+	for _, s := range keys.scopes() {
+		width := 0
+		for _, b := range s.bindings {
+			width = max(width, lipgloss.Width(b.binding.Help().Key))
+		}
+		lines := []string{ui.accent.Render(s.title)}
+		for _, b := range s.bindings {
+			h := b.binding.Help()
+			lines = append(lines, ui.ok.Width(width+2).Render(h.Key)+ui.muted.Render(h.Desc))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	colWidth := 0
+	for _, s := range sections {
+		colWidth = max(colWidth, lipgloss.Width(s))
+	}
+	perRow := max((m.width-8)/(colWidth+3), 1)
+
+	var rows []string
+	for i := 0; i < len(sections); i += perRow {
+		row := sections[i:min(i+perRow, len(sections))]
+		cells := make([]string, len(row))
+		for j, s := range row {
+			cells[j] = lipgloss.NewStyle().Width(colWidth + 3).MarginBottom(1).Render(s)
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cells...))
+	}
+	rows = append(rows, ui.muted.Render("Press any key to close"))
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		ui.dialog.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)),
+	)
+}
+
+// presetNames lists the keymap presets in order.
+func presetNames() []string {
+	var names []string
+	for name := range keyPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
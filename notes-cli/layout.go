@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// defaultSplit is the share of the window, in percent, taken by the note
+	// list. It is changed in steps of splitStep between minSplit and maxSplit.
+	defaultSplit = 25
+	minSplit     = 15
+	maxSplit     = 60
+	splitStep    = 5
+	// stackWidth is the window width below which the list is shown above
+	// the editor instead of beside it.
+	stackWidth = 80
+	// minStackedList is the smallest height of the stacked list pane.
+	minStackedList = 8
+	// headerLines is the height of the note header: ID, title and summary.
+	headerLines = 3
+	// paneFrame is the space taken by a pane's border on each axis.
+	paneFrame = 2
+	// tabBarLines is the height of the tab bar above the editor.
+	tabBarLines = 1
+)
+
+// layout holds the inner size of each pane for the window size, split and
+// mode. A zero headerH hides the header; zen mode hides the list as well.
+// leftW and rightW are the editor widths when two tabs are side by side.
+type layout struct {
+	stacked, zen     bool
+	listW, listH     int
+	editorW, editorH int
+	leftW, rightW    int
+	headerH          int
+}
+
+func (m model) layout() layout {
+	// One line is left for the status bar.
+	height := max(m.height-1, 1)
+	l := layout{zen: m.zen, stacked: m.width < stackWidth}
+
+	switch {
+	case l.zen:
+		l.editorW, l.editorH = m.width-paneFrame, height-tabBarLines-paneFrame
+	case l.stacked:
+		listOuter := max(height*m.split/100, minStackedList)
+		l.listW, l.listH = m.width-paneFrame, listOuter-paneFrame
+		l.editorW = m.width - paneFrame
+		l.headerH = headerLines
+		l.editorH = height - listOuter - (headerLines + paneFrame) - tabBarLines - paneFrame
+		if l.editorH < 3 {
+			// Too short for the header: give its lines to the editor.
+			l.editorH += headerLines + paneFrame
+			l.headerH = 0
+		}
+	default:
+		listOuter := m.width * m.split / 100
+		l.listW, l.listH = listOuter-paneFrame, height-paneFrame
+		l.editorW = m.width - listOuter - paneFrame
+		l.headerH = headerLines
+		l.editorH = height - (headerLines + paneFrame) - tabBarLines - paneFrame
+	}
+
+	l.listW, l.listH = max(l.listW, 1), max(l.listH, 1)
+	l.editorW, l.editorH = max(l.editorW, 1), max(l.editorH, 1)
+	l.leftW = max((l.editorW+paneFrame)/2-paneFrame, 1)
+	l.rightW = max(l.editorW-l.leftW-paneFrame, 1)
+	return l
+}
+
+// resize fits the list and editor to the current layout. It is called
+// whenever the window size, split or mode changes.
+func (m *model) resize() {
+	l := m.layout()
+	m.list.SetSize(l.listW, l.listH)
+	for i := range m.tabs {
+		width := l.editorW
+		switch {
+		case !m.sideBySide:
+		case i == m.paneTabs[0]:
+			width = l.leftW
+		case i == m.paneTabs[1]:
+			width = l.rightW
+		}
+		m.tabs[i].editor.SetWidth(width)
+		m.tabs[i].editor.SetHeight(max(l.editorH-m.suggestionLines(), 1))
+	}
+}
+
+// setSplit changes the list's share of the window, within bounds.
+func (m *model) setSplit(split int) {
+	m.split = min(max(split, minSplit), maxSplit)
+	m.resize()
+}
+
+// setZen switches the fullscreen editor on or off.
+func (m *model) setZen(zen bool) {
+	m.zen = zen
+	m.resize()
+}
+
+// suggestionLines is the space below the editors for inline completion.
+func (m model) suggestionLines() int {
+	if completion.enabled {
+		return completionLines
+	}
+	return 0
+}
+
+// editorView renders tab i's editor, followed by the suggestion line when
+// inline completion is on.
+func (m model) editorView(i, width int) string {
+	view := linkify(m.tabs[i].editor.View(), m.tabs[i].editor.Value())
+	if m.suggestionLines() == 0 {
+		return view
+	}
+	line := ""
+	if i == m.active {
+		line = m.suggestionLine(width)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, view, line)
+}
+
+// header describes the note in the active tab.
+func (m model) header() string {
+	t := m.tabs[m.active]
+	if t.id == "" {
+		return "No notes available"
+	}
+	item := noteListItem{id: t.id, title: t.title}
+	for _, it := range m.list.Items() {
+		if it.(noteListItem).id == t.id {
+			item = it.(noteListItem)
+		}
+	}
+	title := item.title
+	if t.dirty() {
+		title += " ●"
+	}
+	if m.focus == "title" {
+		title = m.renameInput.View()
+	}
+	header := fmt.Sprintf("ID: %s\nTitle: %s", item.id, title)
+	switch {
+	case m.summarizing:
+		header += "\nSummary: generating..."
+	case item.summary != "":
+		header += "\nSummary: " + item.summary
+	}
+	return header
+}
+
+// mainView lays out the list, header and editor panes above the status
+// bar. The focused pane's border uses the theme's accent color.
+func (m model) mainView() string {
+	l := m.layout()
+
+	listPane, editorPane := ui.pane, ui.pane
+	if m.focus == "list" || m.focus == "calendar" {
+		listPane = ui.paneFocused
+	} else {
+		editorPane = ui.paneFocused
+	}
+
+	panes := editorPane.Width(l.editorW).Height(l.editorH).Render(m.editorView(m.active, l.editorW))
+	if m.sideBySide {
+		left, right := ui.pane, ui.pane
+		if m.pane == 0 {
+			left = editorPane
+		} else {
+			right = editorPane
+		}
+		panes = lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			left.Width(l.leftW).Height(l.editorH).Render(m.editorView(m.paneTabs[0], l.leftW)),
+			right.Width(l.rightW).Height(l.editorH).Render(m.editorView(m.paneTabs[1], l.rightW)),
+		)
+	}
+	panes = lipgloss.JoinVertical(lipgloss.Left, m.tabBar(l.editorW+paneFrame), panes)
+	if !l.zen {
+		if l.headerH > 0 {
+			header := lipgloss.NewStyle().Width(l.editorW).MaxHeight(l.headerH).Render(m.header())
+			header = editorPane.Width(l.editorW).Height(l.headerH).Render(header)
+			panes = lipgloss.JoinVertical(lipgloss.Left, header, panes)
+		}
+
+		list := m.list.View()
+		if m.focus == "calendar" {
+			list = m.calendar.View()
+		}
+		list = lipgloss.NewStyle().MaxHeight(l.listH).Render(list)
+		list = listPane.Width(l.listW).Height(l.listH).Render(list)
+
+		if l.stacked {
+			panes = lipgloss.JoinVertical(lipgloss.Left, list, panes)
+		} else {
+			panes = lipgloss.JoinHorizontal(lipgloss.Top, list, panes)
+		}
+	}
+
+	status := lipgloss.NewStyle().MaxWidth(m.width).Render(m.statusLine())
+	return lipgloss.JoinVertical(lipgloss.Left, panes, status)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// urlPattern finds web links in note content. Trailing punctuation is
+// trimmed by findLinks, as in "see https://example.com.".
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\x1b]+`)
+
+// link is a URL in a note, at a byte offset of its line.
+type link struct {
+	url       string
+	line, col int
+}
+
+// findLinks returns the links of content in order.
+func findLinks(content string) []link {
+	var links []link
+	for i, line := range strings.Split(content, "\n") {
+		for _, loc := range urlPattern.FindAllStringIndex(line, -1) {
+			url := trimURL(line[loc[0]:loc[1]])
+			links = append(links, link{url: url, line: i, col: loc[0]})
+		}
+	}
+	return links
+}
+
+func trimURL(url string) string {
+	url = strings.TrimRight(url, ".,;:!?")
+	// Keep a closing parenthesis only when the URL opened one, as in
+	// Wikipedia links.
+	for strings.HasSuffix(url, ")") && strings.Count(url, "(") < strings.Count(url, ")") {
+		url = strings.TrimSuffix(url, ")")
+	}
+	return url
+}
+
+// linkAtCursor returns the link of the cursor's line that is under or
+// closest to the cursor.
+func linkAtCursor(t *tab) (string, bool) {
+	before, _ := cursorText(t.editor)
+	col := len(before) - strings.LastIndex(before, "\n") - 1
+	best, found := "", false
+	dist := -1
+	for _, l := range findLinks(t.editor.Value()) {
+		if l.line != t.editor.Line() {
+			continue
+		}
+		d := 0
+		switch {
+		case col < l.col:
+			d = l.col - col
+		case col > l.col+len(l.url):
+			d = col - l.col - len(l.url)
+		}
+		if dist < 0 || d < dist {
+			best, found, dist = l.url, true, d
+		}
+	}
+	return best, found
+}
+
+// linkOpenedMsg reports that the opener was started for a link.
+type linkOpenedMsg struct {
+	url string
+	err error
+}
+
+// openerCommand builds the platform's command for opening a URL.
+func openerCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	}
+	return exec.Command("xdg-open", url)
+}
+
+// openLinkCmd opens a URL with the platform opener without waiting for it.
+func openLinkCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		cmd := openerCommand(url)
+		err := cmd.Start()
+		if err == nil {
+			go cmd.Wait()
+		}
+		return linkOpenedMsg{url: url, err: err}
+	}
+}
+
+func (m *model) handleLinkOpened(msg linkOpenedMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.notify(fmt.Sprintf("Opening %s failed: %v", msg.url, msg.err), true)
+	}
+	return m.notify("Opened "+msg.url, false)
+}
+
+// openNoteLink opens the first link of a note, mentioning any others.
+func (m *model) openNoteLink(item noteListItem) tea.Cmd {
+	links := findLinks(item.content)
+	switch len(links) {
+	case 0:
+		return m.notify("No links in this note", true)
+	case 1:
+		return openLinkCmd(links[0].url)
+	}
+	return tea.Batch(
+		openLinkCmd(links[0].url),
+		m.notify(fmt.Sprintf("Opened the first of %d links; open the others from the editor", len(links)), false),
+	)
+}
+
+// hyperlinks reports whether the terminal shows OSC 8 hyperlinks.
+// NOTES_HYPERLINKS=on or off overrides the guess from the environment.
+var hyperlinks = detectHyperlinks()
+
+func detectHyperlinks() bool {
+	switch os.Getenv("NOTES_HYPERLINKS") {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "rio":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.Contains(term, "kitty") || strings.Contains(term, "foot") || strings.Contains(term, "alacritty")
+}
+
+// hyperlink wraps text in an OSC 8 sequence pointing at url.
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// linkify turns the links of a rendered editor into OSC 8 hyperlinks. A
+// link cut off by soft wrapping still points at the full URL of the note.
+func linkify(view, content string) string {
+	if !hyperlinks {
+		return view
+	}
+	links := findLinks(content)
+	if len(links) == 0 {
+		return view
+	}
+	return urlPattern.ReplaceAllStringFunc(view, func(text string) string {
+		shown := trimURL(text)
+		for _, l := range links {
+			if strings.HasPrefix(l.url, shown) {
+				return hyperlink(l.url, shown) + text[len(shown):]
+			}
+		}
+		return text
+	})
+}
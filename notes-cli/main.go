@@ -1,302 +1,924 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
-	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-var (
-	listStyle       = lipgloss.NewStyle().Margin(1, 1).Border(lipgloss.RoundedBorder())
-	noteHeaderStyle = lipgloss.NewStyle().Width(80).Height(1).Border(lipgloss.RoundedBorder())
-	noteStyle       = lipgloss.NewStyle().Width(80).Height(29).Border(lipgloss.RoundedBorder())
-)
-
 type noteListItem struct {
-	id, title, content string
-	createdAt          time.Time
+	id, title, content, summary string
+	pinned, archived, favorite  bool
+	locked                      bool
+	tags                        []string
+	createdAt, updatedAt        time.Time
 }
 
-func (i noteListItem) ID() string    { return i.id }
-func (i noteListItem) Title() string { return i.title }
+func (i noteListItem) ID() string { return i.id }
+func (i noteListItem) Title() string {
+	title := i.title
+	if i.favorite {
+		title = "★ " + title
+	}
+	if i.pinned {
+		title = "⚑ " + title
+	}
+	if i.locked {
+		title = "🔒 " + title
+	}
+	return title
+}
 func (i noteListItem) Description() string {
-	return fmt.Sprintf("Created: %s", i.createdAt.Format("2006-01-02 15:04"))
+	desc := fmt.Sprintf("Created: %s", i.createdAt.Format("2006-01-02 15:04"))
+	for _, tag := range i.tags {
+		desc += " #" + tag
+	}
+	return desc
 }
 func (i noteListItem) FilterValue() string { return i.title }
 
+func newNoteListItem(note apiNote) noteListItem {
+	locked := !openNote(&note)
+	return noteListItem{
+		id:        note.ID,
+		title:     note.Title,
+		content:   note.Content,
+		summary:   note.Summary,
+		pinned:    note.Pinned,
+		archived:  note.Archived,
+		favorite:  note.Favorite,
+		locked:    locked,
+		tags:      note.Tags,
+		createdAt: note.CreatedAt,
+		updatedAt: note.UpdatedAt,
+	}
+}
+
 type apiNote struct {
 	ID        string    `json:"id"`
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
+	Summary   string    `json:"summary,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Archived  bool      `json:"archived,omitempty"`
+	Favorite  bool      `json:"favorite,omitempty"`
+	Encrypted bool      `json:"encrypted"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// noteView is a list the view switcher cycles through. query selects the
+// notes on the server and match selects them from the local cache.
+type noteView struct {
+	name, title, query string
+	match              func(apiNote) bool
+}
+
+var noteViews = []noteView{
+	{"all", "Notes", "", func(n apiNote) bool { return !n.Archived }},
+	{"favorites", "Favorites", "?favorite=true", func(n apiNote) bool { return n.Favorite && !n.Archived }},
+	{"archived", "Archived", "?archived=true", func(n apiNote) bool { return n.Archived }},
+}
+
+// syncMsg triggers a sync attempt. Ticks from an outdated schedule carry an
+// old gen and are ignored.
+type syncMsg struct{ gen int }
+
+type apiTemplate struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Prompts []string `json:"prompts"`
+}
+
+type summaryMsg struct {
+	id      string
+	summary string
+	err     error
 }
 
 type model struct {
-	list       list.Model
-	textarea   textarea.Model
-	titleInput textinput.Model
-	cursor     int
-	focus      string
-	creating   bool
-	width      int
-	height     int
+	list        list.Model
+	tabs        []tab
+	active      int
+	sideBySide  bool
+	pane        int
+	paneTabs    [2]int
+	unsaved     string
+	closing     string
+	quitting    bool
+	journalDue  bool
+	titleInput  textinput.Model
+	renameInput textinput.Model
+	spinner     spinner.Model
+	cursor      int
+	focus       string
+	creating    bool
+	deleting    bool
+	summarizing bool
+	templates   []apiTemplate
+	template    int
+	prompt      int
+	newTitle    string
+	vars        map[string]string
+	calendar    calendar
+	search      search
+	sortBy      int
+	groupBy     int
+	marked      map[string]bool
+	bulk        string
+	bulkInput   textinput.Model
+	suggest     suggestion
+	showHelp    bool
+	split       int
+	dragging    bool
+	zen         bool
+	view        int
+	selectID    string
+	conflicts   []conflict
+	pending     int
+	offline     bool
+	syncing     bool
+	syncGen     int
+	syncDelay   time.Duration
+	nextSync    time.Time
+	statusErr   string
+	toast       toast
+	width       int
+	height      int
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{m.spinner.Tick, syncCmd()}
+	if n := m.dirtyTabs(); n > 0 {
+		cmds = append(cmds, func() tea.Msg { return draftsRestoredMsg{n: n} })
+	}
+	return tea.Batch(cmds...)
 }
 
+// Update handles msg and then writes the journal if handling it changed
+// what is unsaved.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if m, ok := next.(model); ok && m.journalDue {
+		journal := m.journalCmd()
+		return m, tea.Batch(cmd, journal)
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case syncMsg:
+		if msg.gen != m.syncGen {
+			return m, nil
+		}
+		if _, queued := local.state(); m.offline || queued > 0 {
+			m.syncing = true
+			return m, m.run(syncCmd())
+		}
+		return m, m.scheduleSync(syncInterval)
+
+	case syncDoneMsg:
+		m.done()
+		m.syncing = false
+		m.addConflicts(msg.conflicts)
+		wasOffline := m.offline
+		m.offline, _ = local.state()
+
+		cmds := []tea.Cmd{m.run(loadNotesCmd(m.view))}
+		switch {
+		case m.offline:
+			cmds = append(cmds, m.scheduleSync(min(max(m.syncDelay*2, reconnectDelay), syncInterval)))
+		case wasOffline:
+			cmds = append(cmds, m.notify("Reconnected to "+api.serverURL(), false), m.scheduleSync(syncInterval))
+		default:
+			cmds = append(cmds, m.scheduleSync(syncInterval))
+		}
+		if msg.err != nil {
+			cmds = append(cmds, m.checkOnline(msg.err, "Syncing"))
+		}
+		return m, tea.Batch(cmds...)
+
+	case notesLoadedMsg:
+		m.done()
+		if msg.view != m.view {
+			return m, nil
+		}
+		m.setItems(msg.items)
+		if m.selectID != "" {
+			m.selectNote(m.selectID)
+			m.selectID = ""
+		}
+		return m, m.checkOnline(msg.err, "Loading notes")
+
+	case opDoneMsg:
+		m.done()
+		if msg.selectID != "" {
+			m.selectID = msg.selectID
+		}
+		if cmd := m.checkOnline(msg.err, msg.action); cmd != nil {
+			return m, tea.Batch(cmd, m.run(loadNotesCmd(m.view)))
+		}
+		m.statusErr = ""
+		return m, m.run(loadNotesCmd(m.view))
+
+	case templatesMsg:
+		m.done()
+		m.templates = msg.templates
+		return m, m.checkOnline(msg.err, "Loading templates")
+
+	case calendarMsg:
+		m.done()
+		if msg.month == m.calendar.month() {
+			m.calendar.entries = msg.entries
+		}
+		return m, m.checkOnline(msg.err, "Loading calendar")
+
+	case dailyMsg:
+		m.done()
+		if msg.err != nil {
+			return m, m.checkOnline(msg.err, "Opening daily note")
+		}
+		m.view = 0
+		m.selectID = msg.note.ID
+		m.focus = "content"
+		m.showNote(noteListItem{id: msg.note.ID, title: msg.note.Title, content: msg.note.Content}, false)
+		m.activeTab().editor.Focus()
+		return m, m.run(loadNotesCmd(m.view))
+
+	case tabSavedMsg:
+		m.done()
+		return m, m.handleTabSaved(msg)
+
+	case autosaveMsg:
+		return m, m.handleAutosave(msg)
+
+	case suggestTickMsg:
+		return m, m.handleSuggestTick(msg)
+
+	case suggestMsg:
+		return m, m.handleSuggestion(msg)
+
+	case draftsRestoredMsg:
+		text := fmt.Sprintf("Restored %d unsaved drafts from the last session", msg.n)
+		if msg.n == 1 {
+			text = "Restored an unsaved draft from the last session"
+		}
+		return m, m.notify(text, false)
+
+	case editorDoneMsg:
+		return m, m.handleEditorDone(msg)
+
+	case editorSavedMsg:
+		m.done()
+		m.addConflicts(msg.conflicts)
+		cmds := []tea.Cmd{m.run(loadNotesCmd(m.view))}
+		if msg.err != nil {
+			cmds = append(cmds, m.checkOnline(msg.err, "Saving note"))
+		} else if msg.notice != "" {
+			cmds = append(cmds, m.notify(msg.notice, msg.warn))
+		}
+		return m, tea.Batch(cmds...)
+
+	case searchMsg:
+		m.done()
+		return m, m.handleSearchResults(msg)
+
+	case linkOpenedMsg:
+		return m, m.handleLinkOpened(msg)
+
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
+	case exportedMsg:
+		m.done()
+		return m, m.handleExported(msg)
+
+	case toastExpiredMsg:
+		if msg.id == m.toast.id {
+			m.toast = toast{}
+		}
+		return m, nil
+
+	case spinner.TickMsg:
+		if m.pending == 0 {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
-			return m, tea.Quit
-		case "ctrl+n":
-			if !m.creating && m.focus == "list" {
-				m.creating = true
-				m.titleInput.Reset()
-				return m, textinput.Blink
-			}
-		case "esc":
-			if m.creating {
-				m.creating = false
-				return m, nil
-			} else {
-				m.focus = "list"
-			}
-		case "enter":
-			if m.creating {
-				title := m.titleInput.Value()
-				if title != "" {
-					createNote(title, "")
-					m.list.SetItems(loadNotes())
-				}
-				m.creating = false
-				return m, nil
-			} else {
-				m.focus = "content"
-				m.textarea.Focus()
-			}
-		case "ctrl+b":
-			m.focus = "list"
-			updateNote(m.list.SelectedItem().(noteListItem).ID(), m.list.SelectedItem().(noteListItem).title, m.textarea.Value())
-			m.list.SetItems(loadNotes())
-		case "up", "k":
-			if m.cursor > 0 && m.focus == "list" {
-				m.cursor--
-				m.textarea.SetValue(m.list.Items()[m.cursor].(noteListItem).content)
-			}
-		case "down", "j":
-			if m.cursor < len(m.list.Items())-1 && m.focus == "list" {
-				m.cursor++
-				m.textarea.SetValue(m.list.Items()[m.cursor].(noteListItem).content)
-			}
-		case "d":
-			if m.focus == "list" {
-				deleteNote(m.list.SelectedItem().(noteListItem).ID())
-				m.list.RemoveItem(m.cursor)
-			}
+		if key.Matches(msg, keys.Global.Quit) {
+			return m, m.quit()
+		}
+		switch {
+		case m.unsaved != "":
+			return m.updateUnsaved(msg)
+		case m.showHelp:
+			m.showHelp = false
+			return m, nil
+		case len(m.conflicts) > 0:
+			return m.resolveConflict(msg)
+		case m.deleting:
+			return m.confirmDelete(msg)
+		case m.creating:
+			return m.updateCreate(msg)
+		case m.bulk != "":
+			return m.updateBulk(msg)
+		}
+		switch m.focus {
+		case "list":
+			return m.updateList(msg)
+		case "calendar":
+			return m.updateCalendar(msg)
+		case "title":
+			return m.updateRename(msg)
+		case "search":
+			return m.updateSearch(msg)
+		default:
+			return m.updateEditor(msg)
 		}
 
-		if m.creating {
-			var cmd tea.Cmd
-			m.titleInput, cmd = m.titleInput.Update(msg)
-			return m, cmd
+	case summaryMsg:
+		m.done()
+		m.summarizing = false
+		if msg.err != nil {
+			return m, m.checkOnline(msg.err, "Summarizing note")
+		}
+		for i, it := range m.list.Items() {
+			item := it.(noteListItem)
+			if item.id == msg.id {
+				item.summary = msg.summary
+				m.list.SetItem(i, item)
+			}
 		}
+		return m, nil
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		h, v := listStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v)
+		m.resize()
 	}
 
 	var cmd tea.Cmd
-	if m.focus == "list" {
+	switch m.focus {
+	case "list":
 		m.list, cmd = m.list.Update(msg)
-	} else {
-		m.textarea, cmd = m.textarea.Update(msg)
+	case "title":
+		m.renameInput, cmd = m.renameInput.Update(msg)
+	default:
+		m.tabs[m.active].editor, cmd = m.tabs[m.active].editor.Update(msg)
 	}
 	return m, cmd
 }
 
-func (m model) View() string {
-	listWidth := m.width / 4
-	contentWidth := m.width * 7 / 10
-	contentHeight := m.height - 10
+// updateList handles keys while the note list has focus.
+func (m model) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	k := keys.List
+	item, ok := m.list.SelectedItem().(noteListItem)
+
+	switch {
+	case key.Matches(msg, k.Up):
+		m.moveCursor(m.cursor - 1)
+	case key.Matches(msg, k.Down):
+		m.moveCursor(m.cursor + 1)
+	case key.Matches(msg, k.Top):
+		m.moveCursor(0)
+	case key.Matches(msg, k.Bottom):
+		m.moveCursor(len(m.list.Items()) - 1)
+	case key.Matches(msg, k.Help):
+		m.showHelp = true
+	case key.Matches(msg, k.NextTab):
+		m.cycleTab(1)
+	case key.Matches(msg, k.PrevTab):
+		m.cycleTab(-1)
+	case key.Matches(msg, k.CloseTab):
+		m.closeTab()
+	case key.Matches(msg, k.Split):
+		return m, m.toggleSplit()
+	case key.Matches(msg, k.SaveAll):
+		return m, m.saveAll()
+	case key.Matches(msg, k.Shrink):
+		m.setSplit(m.split - splitStep)
+	case key.Matches(msg, k.Grow):
+		m.setSplit(m.split + splitStep)
+	case key.Matches(msg, k.Search):
+		return m, m.openSearch()
+	case key.Matches(msg, k.Calendar):
+		m.calendar = newCalendar(time.Now())
+		m.focus = "calendar"
+		return m, m.run(loadCalendarCmd(m.calendar.month()))
+	case key.Matches(msg, k.New):
+		m.creating = true
+		m.templates = nil
+		m.template = -1
+		m.resetTitleInput()
+		return m, tea.Batch(textinput.Blink, m.run(loadTemplatesCmd()))
+	case key.Matches(msg, k.Sort):
+		return m, m.cycleSort()
+	case key.Matches(msg, k.Group):
+		return m, m.cycleGroup()
+	case key.Matches(msg, k.ClearMarks):
+		m.clearMarks()
+	case key.Matches(msg, k.Tag):
+		return m, m.openBulk("tag")
+	case key.Matches(msg, k.Export):
+		return m, m.openBulk("export")
+	case key.Matches(msg, k.View):
+		m.view = (m.view + 1) % len(noteViews)
+		m.list.Title = noteViews[m.view].title
+		m.clearMarks()
+		m.cursor = 0
+		m.list.Select(0)
+		return m, m.run(loadNotesCmd(m.view))
+	case !ok:
+		// The remaining bindings act on the selected note.
+	case key.Matches(msg, k.Mark):
+		m.toggleMark(item)
+	case key.Matches(msg, k.OpenLink):
+		if item.locked {
+			return m, nil
+		}
+		return m, m.openNoteLink(item)
+	case key.Matches(msg, k.Open, k.OpenTab, k.Zen):
+		m.showNote(item, key.Matches(msg, k.OpenTab))
+		m.focus = "content"
+		m.activeTab().editor.Focus()
+		if key.Matches(msg, k.Zen) {
+			m.setZen(true)
+		}
+	case key.Matches(msg, k.Delete):
+		m.deleting = true
+	case key.Matches(msg, k.Edit):
+		if item.locked {
+			return m, m.notify("Refusing to edit a note that could not be decrypted", true)
+		}
+		return m, openEditor(item)
+	case key.Matches(msg, k.Rename):
+		if !item.locked {
+			m.showNote(item, false)
+			m.focus = "title"
+			m.renameInput.SetValue(item.title)
+			m.renameInput.CursorEnd()
+			return m, m.renameInput.Focus()
+		}
+	case key.Matches(msg, k.Duplicate):
+		if !item.locked {
+			return m, m.run(apiSelectCmd("Duplicating note", func() (string, error) {
+				return createNote(item.title+" (copy)", item.content)
+			}))
+		}
+	case key.Matches(msg, k.Pin, k.Archive, k.Favorite):
+		flag := "favorite"
+		switch {
+		case key.Matches(msg, k.Pin):
+			flag = "pin"
+		case key.Matches(msg, k.Archive):
+			flag = "archive"
+		}
+		return m, m.run(apiCmd("Updating note", func() error {
+			return api.toggleFlag(item.id, flag)
+		}))
+	case key.Matches(msg, k.Summarize):
+		if !m.summarizing {
+			m.summarizing = true
+			return m, m.run(summarizeNote(item.id))
+		}
+	}
+	return m, nil
+}
 
-	listStyle = listStyle.Width(listWidth).Height(contentHeight)
-	noteStyle = noteStyle.Width(contentWidth).Height(contentHeight)
-	noteHeaderStyle = noteHeaderStyle.Width(contentWidth).Height(2)
-	m.textarea.SetWidth(contentWidth)
-	m.textarea.SetHeight(contentHeight - 2)
+// moveCursor selects the note at index i, if there is one, and shows it in
+// the active tab unless that tab has unsaved changes.
+func (m *model) moveCursor(i int) {
+	if i < 0 || i >= len(m.list.Items()) {
+		return
+	}
+	m.cursor = i
+	m.list.Select(i)
+	if !m.activeTab().dirty() {
+		m.showNote(m.list.Items()[i].(noteListItem), false)
+	}
+}
 
-	var header string
+// updateEditor handles keys while the note content is being edited. Keys
+// that are not bound go to the textarea.
+func (m model) updateEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if cmd, ok := m.updateCompletion(msg); ok {
+		return m, cmd
+	}
 
-	if len(m.list.Items()) > 0 && m.cursor < len(m.list.Items()) {
-		item := m.list.Items()[m.cursor].(noteListItem)
-		header = fmt.Sprintf("ID: %s\nTitle: %s", item.id, item.title)
-	} else {
-		header = "No notes available"
+	k := keys.Editor
+	switch {
+	case key.Matches(msg, k.Zen):
+		m.setZen(!m.zen)
+		return m, nil
+	case key.Matches(msg, k.NextTab):
+		m.cycleTab(1)
+		return m, nil
+	case key.Matches(msg, k.PrevTab):
+		m.cycleTab(-1)
+		return m, nil
+	case key.Matches(msg, k.OtherPane):
+		m.otherPane()
+		return m, nil
+	case key.Matches(msg, k.CloseTab):
+		m.closeTab()
+		return m, nil
+	case key.Matches(msg, k.SaveAll):
+		return m, m.saveAll()
+	case key.Matches(msg, k.OpenLink):
+		url, ok := linkAtCursor(m.activeTab())
+		if !ok {
+			return m, m.notify("No link on this line", true)
+		}
+		return m, openLinkCmd(url)
+	case key.Matches(msg, k.Back):
+		m.focus = "list"
+		m.activeTab().editor.Blur()
+		m.setZen(false)
+		return m, nil
+	case key.Matches(msg, k.Save):
+		m.focus = "list"
+		m.activeTab().editor.Blur()
+		m.setZen(false)
+		if m.activeTab().id == "" {
+			return m, nil
+		}
+		return m, m.saveTab(*m.activeTab())
 	}
 
-	listBorder := listStyle
-	headerBorder := noteHeaderStyle
-	contentBorder := noteStyle
+	t := m.activeTab()
+	before := t.editor.Value()
+	var cmd tea.Cmd
+	t.editor, cmd = t.editor.Update(msg)
+	if t.id != "" && t.editor.Value() != before {
+		cmd = tea.Batch(cmd, m.scheduleAutosave(t), m.scheduleSuggestion())
+	}
+	return m, cmd
+}
 
-	if m.focus == "list" {
-		listBorder = listBorder.BorderForeground(lipgloss.Color("213"))
-	} else {
-		contentBorder = contentBorder.BorderForeground(lipgloss.Color("213"))
-		headerBorder = headerBorder.BorderForeground(lipgloss.Color("213"))
+// updateCreate handles keys in the new note dialog.
+func (m model) updateCreate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	k := keys.Dialog
+	switch {
+	case key.Matches(msg, k.Cancel):
+		m.creating = false
+		m.resetTitleInput()
+		return m, nil
+	case key.Matches(msg, k.Confirm):
+		return m.submitCreate()
+	case key.Matches(msg, k.NextTemplate, k.PrevTemplate):
+		if m.prompt < 0 && len(m.templates) > 0 {
+			n := len(m.templates) + 1
+			if key.Matches(msg, k.NextTemplate) {
+				m.template = (m.template+2)%n - 1
+			} else {
+				m.template = (m.template+n)%n - 1
+			}
+		}
+		return m, nil
 	}
 
-	mainView := lipgloss.JoinHorizontal(
-		lipgloss.Center,
-		listBorder.Render(m.list.View()),
-		lipgloss.JoinVertical(
-			lipgloss.Center,
-			headerBorder.Render(header),
-			contentBorder.Render(m.textarea.View()),
-		),
-	)
+	var cmd tea.Cmd
+	m.titleInput, cmd = m.titleInput.Update(msg)
+	return m, cmd
+}
 
-	if m.creating {
-		return lipgloss.Place(
-			m.list.Width(),
-			m.list.Height(),
-			lipgloss.Center,
-			lipgloss.Center,
-			lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("213")).
-				Padding(1, 2).
-				Render(
-					lipgloss.JoinVertical(
-						lipgloss.Center,
-						m.titleInput.View(),
-						lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Press Enter to save • Esc to cancel"),
-					),
-				),
-		)
+// updateRename handles keys while the title is edited in the header pane.
+func (m model) updateRename(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Dialog.Cancel):
+		m.focus = "list"
+		m.renameInput.Blur()
+		return m, nil
+	case key.Matches(msg, keys.Dialog.Confirm):
+		m.focus = "list"
+		m.renameInput.Blur()
+		item, ok := m.list.SelectedItem().(noteListItem)
+		title := m.renameInput.Value()
+		if !ok || title == "" || title == item.title {
+			return m, nil
+		}
+		m.selectID = item.id
+		return m, m.run(apiCmd("Renaming note", func() error {
+			return updateNote(item.id, title, item.content)
+		}))
 	}
 
-	return mainView
+	var cmd tea.Cmd
+	m.renameInput, cmd = m.renameInput.Update(msg)
+	return m, cmd
 }
 
-func loadNotes() []list.Item {
-	resp, err := http.Get("http://localhost:3000/notes")
-	if err != nil {
-		log.Printf("Error fetching notes: %v", err)
-		return nil
+// confirmDelete asks before the selected or marked notes are deleted.
+func (m model) confirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Confirm.Yes):
+		m.deleting = false
+		notes := m.targets()
+		for _, item := range notes {
+			for i, it := range m.list.Items() {
+				if it.(noteListItem).id == item.id {
+					m.list.RemoveItem(i)
+					break
+				}
+			}
+			if i := m.openTab(item.id); i >= 0 {
+				m.removeTab(i)
+			}
+		}
+		m.clearMarks()
+		m.cursor = min(m.cursor, max(len(m.list.Items())-1, 0))
+		m.list.Select(m.cursor)
+		return m, m.run(apiCmd("Deleting notes", func() error {
+			for _, item := range notes {
+				if err := deleteNote(item.id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	case key.Matches(msg, keys.Confirm.No):
+		m.deleting = false
 	}
-	defer resp.Body.Close()
-	var apiNotes []apiNote
-	if err := json.NewDecoder(resp.Body).Decode(&apiNotes); err != nil {
-		log.Printf("Error decoding response: %v", err)
-		return nil
+	return m, nil
+}
+
+// submitCreate advances the create dialog. Notes without a template are
+// created straight away; with a template, the dialog first asks for each of
+// the template's prompts in turn.
+func (m model) submitCreate() (tea.Model, tea.Cmd) {
+	value := m.titleInput.Value()
+
+	if m.template < 0 {
+		m.creating = false
+		if value == "" {
+			return m, nil
+		}
+		return m, m.run(apiSelectCmd("Creating note", func() (string, error) {
+			return createNote(value, "")
+		}))
+	}
+
+	t := m.templates[m.template]
+	if m.prompt < 0 {
+		m.newTitle = value
+		m.vars = map[string]string{}
+	} else {
+		m.vars[t.Prompts[m.prompt]] = value
 	}
 
-	items := make([]list.Item, len(apiNotes))
-	for i, note := range apiNotes {
-		items[i] = noteListItem{
-			id:        note.ID,
-			title:     note.Title,
-			content:   note.Content,
-			createdAt: note.CreatedAt,
+	if m.prompt+1 < len(t.Prompts) {
+		m.prompt++
+		m.titleInput.Reset()
+		m.titleInput.Placeholder = t.Prompts[m.prompt]
+		m.titleInput.CharLimit = 200
+		return m, nil
+	}
+
+	title, vars := m.newTitle, m.vars
+	m.creating = false
+	m.resetTitleInput()
+	return m, m.run(apiCmd("Creating note", func() error {
+		return createNoteFromTemplate(t.ID, title, vars)
+	}))
+}
+
+func (m *model) resetTitleInput() {
+	m.prompt = -1
+	m.titleInput.Reset()
+	m.titleInput.Placeholder = "Title"
+	m.titleInput.CharLimit = 50
+}
+
+func (m model) createDialogLabel() string {
+	if m.template < 0 {
+		if len(m.templates) == 0 {
+			return "New note"
 		}
+		return "Template: none"
 	}
-	return items
+	t := m.templates[m.template]
+	if m.prompt < 0 {
+		return "Template: " + t.Name
+	}
+	return fmt.Sprintf("%s: %s (%d/%d)", t.Name, t.Prompts[m.prompt], m.prompt+1, len(t.Prompts))
 }
 
-func createNote(title, content string) {
-	note := apiNote{
-		Title:   title,
-		Content: content,
+func (m model) View() string {
+	if m.unsaved != "" {
+		return m.unsavedView()
 	}
-	body, _ := json.Marshal(note)
 
-	resp, err := http.Post("http://localhost:3000/notes", "application/json", bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("Error creating note: %v", err)
+	if len(m.conflicts) > 0 {
+		return m.conflictView()
 	}
-	defer resp.Body.Close()
-}
 
-func updateNote(id, title, content string) {
-	note := apiNote{
-		Title:   title,
-		Content: content,
+	if m.showHelp {
+		return m.helpView()
 	}
-	body, _ := json.Marshal(note)
 
-	req, _ := http.NewRequest(http.MethodPut, "http://localhost:3000/notes/"+id, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("Error updating note: %v", err)
+	if m.focus == "search" {
+		return m.searchView()
+	}
+
+	if m.bulk != "" {
+		return m.bulkView()
+	}
+
+	if m.deleting {
+		question := fmt.Sprintf("Delete %d notes?", len(m.targets()))
+		if notes := m.targets(); len(notes) == 1 {
+			question = fmt.Sprintf("Delete %q?", notes[0].title)
+		}
+		return lipgloss.Place(
+			m.width,
+			m.height,
+			lipgloss.Center,
+			lipgloss.Center,
+			ui.dialog.Render(
+				lipgloss.JoinVertical(
+					lipgloss.Center,
+					question,
+					ui.muted.Render(shortHelp(keys.Confirm.Yes, keys.Confirm.No)),
+				),
+			),
+		)
+	}
+
+	if m.creating {
+		k := keys.Dialog
+		help := shortHelp(k.Confirm, k.Cancel)
+		if len(m.templates) > 0 && m.prompt < 0 {
+			help = shortHelp(k.NextTemplate, k.Confirm, k.Cancel)
+		}
+		return lipgloss.Place(
+			m.width,
+			m.height,
+			lipgloss.Center,
+			lipgloss.Center,
+			ui.dialog.Render(
+				lipgloss.JoinVertical(
+					lipgloss.Center,
+					m.createDialogLabel(),
+					m.titleInput.View(),
+					ui.muted.Render(help),
+				),
+			),
+		)
 	}
-	defer resp.Body.Close()
+
+	return m.mainView()
 }
 
-func deleteNote(id string) {
-	req, _ := http.NewRequest(http.MethodDelete, "http://localhost:3000/notes/"+id, nil)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Printf("Error deleting note: %v", err)
+// setItems replaces the list contents and keeps the cursor in range. Open
+// tabs are refreshed, and the selected note is shown unless the editor is
+// being typed in or has unsaved changes.
+func (m *model) setItems(items []list.Item) {
+	arrange(items, m.groupBy, m.sortBy)
+	m.list.SetItems(items)
+	m.list.Title = noteViews[m.view].title
+	if m.cursor >= len(items) {
+		m.cursor = max(len(items)-1, 0)
+	}
+	m.list.Select(m.cursor)
+	m.pruneMarks()
+
+	notes := make([]noteListItem, len(items))
+	for i, it := range items {
+		notes[i] = it.(noteListItem)
+	}
+	m.refreshTabs(notes)
+	if m.focus == "content" || m.activeTab().dirty() {
+		return
+	}
+	if len(notes) > 0 {
+		m.showNote(notes[m.cursor], false)
+	} else if m.activeTab().id == "" {
+		m.activeTab().editor.Placeholder = "No notes available"
 	}
-	defer resp.Body.Close()
 }
 
-func initialModel() model {
-	items := loadNotes()
-	ta := textarea.New()
-	if len(items) > 0 {
-		ta.Placeholder = items[0].(noteListItem).content
-		if ta.Placeholder == "" {
-			ta.Placeholder = "This note is empty"
+// selectNote moves the cursor to the note with the given ID.
+func (m *model) selectNote(id string) {
+	for i, it := range m.list.Items() {
+		if it.(noteListItem).id == id {
+			m.list.Select(i)
+			m.cursor = i
+			return
 		}
-	} else {
-		ta.Placeholder = "No notes available"
 	}
+}
 
-	ta.SetWidth(80)
-	ta.SetHeight(20)
-	ta.ShowLineNumbers = true
+// newNoteList returns the note list. Its built-in filter only sees titles,
+// so filtering is left to the search overlay.
+func newNoteList() list.Model {
+	accent := lipgloss.Color(ui.theme.Accent)
+	l := list.New(nil, newNoteDelegate(nil, 0, 0), 100, 100)
+	l.Styles.Title = l.Styles.Title.Background(accent).Foreground(lipgloss.Color(ui.theme.ToastText))
+	l.SetFilteringEnabled(false)
+	// Keys are handled by updateList; the list only shows a few of them.
+	l.KeyMap = list.KeyMap{}
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{keys.List.Open, keys.List.New, keys.List.Search, keys.List.Help}
+	}
+	return l
+}
 
+func initialModel() model {
+	local = loadNoteCache()
 	ti := textinput.New()
 	ti.Placeholder = "Title"
 	ti.Focus()
 	ti.CharLimit = 50
 	ti.Width = 30
 
+	ri := textinput.New()
+	ri.Prompt = ""
+	ri.CharLimit = 50
+
+	si := textinput.New()
+	si.Placeholder = "Search notes"
+	si.Width = 40
+
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+
+	tabs := restoreDrafts()
+	if len(tabs) == 0 {
+		tabs = []tab{{editor: newEditor()}}
+	}
+
 	return model{
-		list:       list.New(items, list.NewDefaultDelegate(), 100, 100),
-		textarea:   ta,
-		cursor:     0,
-		focus:      "list",
-		titleInput: ti,
-		width:      80,
-		height:     24,
+		list:        newNoteList(),
+		tabs:        tabs,
+		titleInput:  ti,
+		renameInput: ri,
+		search:      search{input: si},
+		bulkInput:   newBulkInput(),
+		spinner:     sp,
+		cursor:      0,
+		focus:       "list",
+		template:    -1,
+		prompt:      -1,
+		pending:     1,
+		syncing:     true,
+		split:       defaultSplit,
+		width:       80,
+		height:      24,
 	}
 }
 
+// notesVault encrypts notes client-side. It is enabled by setting
+// NOTES_PASSPHRASE; NOTES_ENCRYPT_TITLE=true encrypts titles as well.
+var notesVault *vault
+
+// local caches notes and queues changes made while the server is down.
+var local *noteCache
+
 func main() {
+	args, err := setup(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitUsage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if passphrase := os.Getenv("NOTES_PASSPHRASE"); passphrase != "" {
+		notesVault = newVault(passphrase, os.Getenv("NOTES_ENCRYPT_TITLE") == "true")
+		if dir, err := os.UserCacheDir(); err == nil {
+			notesVault.useSalt(filepath.Join(dir, "notes-cli", "vault.salt"))
+		}
+	}
+
+	if len(args) > 0 {
+		if args[0] == "help" {
+			printUsage(os.Stdout)
+			return
+		}
+		stat, err := os.Stdin.Stat()
+		piped := err == nil && stat.Mode()&os.ModeCharDevice == 0
+		os.Exit(runCommand(args, os.Stdin, piped, os.Stdout, os.Stderr))
+	}
+
 	m := initialModel()
-	m.list.Title = "Notes"
+	m.list.Title = noteViews[0].title
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+)
+
+// lcsMatches returns, for every line of a that belongs to a longest common
+// subsequence of a and b, the index of the matching line in b. Unmatched
+// lines map to -1.
+func lcsMatches(a, b []string) []int {
+	n, m := len(a), len(b)
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	matches := make([]int, n)
+	for i := range matches {
+		matches[i] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// merge3 performs a line-based three-way merge of two edits, mine and
+// theirs, of a common base. It reports whether the merge was clean; if not,
+// the result contains conflict markers around the overlapping changes.
+func merge3(base, mine, theirs string) (string, bool) {
+	o, a, b := splitLines(base), splitLines(mine), splitLines(theirs)
+	ma, mb := lcsMatches(o, a), lcsMatches(o, b)
+
+	var out []string
+	clean := true
+	i, ja, jb := 0, 0, 0
+
+	emitChunk := func(oc, ac, bc []string) {
+		switch {
+		case equalLines(ac, oc):
+			out = append(out, bc...)
+		case equalLines(bc, oc), equalLines(ac, bc):
+			out = append(out, ac...)
+		default:
+			clean = false
+			out = append(out, "<<<<<<< local")
+			out = append(out, ac...)
+			out = append(out, "=======")
+			out = append(out, bc...)
+			out = append(out, ">>>>>>> server")
+		}
+	}
+
+	for {
+		// Find the next base line that both sides kept.
+		next := -1
+		for k := i; k < len(o); k++ {
+			if ma[k] >= 0 && mb[k] >= 0 {
+				next = k
+				break
+			}
+		}
+		if next < 0 {
+			emitChunk(o[i:], a[ja:], b[jb:])
+			break
+		}
+
+		if next > i || ma[next] > ja || mb[next] > jb {
+			emitChunk(o[i:next], a[ja:ma[next]], b[jb:mb[next]])
+		}
+		out = append(out, o[next])
+		i, ja, jb = next+1, ma[next]+1, mb[next]+1
+	}
+
+	return strings.Join(out, "\n"), clean
+}
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// diffLines returns a line diff turning from into to.
+func diffLines(from, to string) []diffLine {
+	a, b := splitLines(from), splitLines(to)
+	matches := lcsMatches(a, b)
+
+	var out []diffLine
+	j := 0
+	for i, line := range a {
+		if matches[i] < 0 {
+			out = append(out, diffLine{'-', line})
+			continue
+		}
+		for ; j < matches[i]; j++ {
+			out = append(out, diffLine{'+', b[j]})
+		}
+		out = append(out, diffLine{' ', line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		base, mine, theirs string
+		want               string
+		clean              bool
+	}{
+		{"unchanged", "a\nb", "a\nb", "a\nb", "a\nb", true},
+		{"only mine", "a\nb\nc", "a\nB\nc", "a\nb\nc", "a\nB\nc", true},
+		{"only theirs", "a\nb\nc", "a\nb\nc", "a\nb\nC", "a\nb\nC", true},
+		{"both, apart", "a\nb\nc\nd", "A\nb\nc\nd", "a\nb\nc\nD", "A\nb\nc\nD", true},
+		{"same change", "a\nb\nc", "a\nB\nc", "a\nB\nc", "a\nB\nc", true},
+		{"insert at end", "a\nb", "a\nb\nmine", "a\nb", "a\nb\nmine", true},
+		{"insert at start and end", "a\nb", "a\nb\nmine", "theirs\na\nb", "theirs\na\nb\nmine", true},
+		{"delete and edit apart", "a\nb\nc\nd", "a\nc\nd", "a\nb\nc\nD", "a\nc\nD", true},
+		{"from empty", "", "mine", "", "mine", true},
+		{
+			"overlapping edits", "a\nb\nc", "a\nmine\nc", "a\ntheirs\nc",
+			"a\n<<<<<<< local\nmine\n=======\ntheirs\n>>>>>>> server\nc", false,
+		},
+		{
+			"both insert at end", "a", "a\nmine", "a\ntheirs",
+			"a\n<<<<<<< local\nmine\n=======\ntheirs\n>>>>>>> server", false,
+		},
+		{
+			"edit against delete", "a\nb\nc", "a\nB\nc", "a\nc",
+			"a\n<<<<<<< local\nB\n=======\n>>>>>>> server\nc", false,
+		},
+	} {
+		got, clean := merge3(tc.base, tc.mine, tc.theirs)
+		if got != tc.want || clean != tc.clean {
+			t.Errorf("%s: merge3 = %q, %v; want %q, %v", tc.name, got, clean, tc.want, tc.clean)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     []diffLine
+	}{
+		{"a\nb", "a\nb", []diffLine{{' ', "a"}, {' ', "b"}}},
+		{"a\nb", "a\nc", []diffLine{{' ', "a"}, {'-', "b"}, {'+', "c"}}},
+		{"a", "a\nb", []diffLine{{' ', "a"}, {'+', "b"}}},
+		{"a\nb", "b", []diffLine{{'-', "a"}, {' ', "b"}}},
+		{"", "a", []diffLine{{'+', "a"}}},
+		{"a", "", []diffLine{{'-', "a"}}},
+	} {
+		if got := diffLines(tc.from, tc.to); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("diffLines(%q, %q) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// region is a part of the main view hit by the mouse.
+type region int
+
+const (
+	regionNone region = iota
+	regionList
+	regionSplit
+	regionHeader
+	regionTabs
+	regionEditor
+)
+
+// hit finds the region at a screen position, and the position relative to
+// the region's top left corner.
+func (m model) hit(x, y int) (region, int, int) {
+	l := m.layout()
+	if !l.zen {
+		if l.stacked {
+			listBottom := l.listH + paneFrame
+			switch {
+			case y == listBottom-1:
+				return regionSplit, x, y
+			case y < listBottom:
+				return regionList, x, y
+			}
+			y -= listBottom
+		} else {
+			listRight := l.listW + paneFrame
+			switch {
+			case x == listRight-1 || x == listRight:
+				return regionSplit, x, y
+			case x < listRight:
+				return regionList, x, y
+			}
+			x -= listRight
+		}
+		if l.headerH > 0 {
+			if y < l.headerH+paneFrame {
+				return regionHeader, x, y
+			}
+			y -= l.headerH + paneFrame
+		}
+	}
+	if y < tabBarLines {
+		return regionTabs, x, y
+	}
+	y -= tabBarLines
+	if y < l.editorH+paneFrame {
+		return regionEditor, x, y
+	}
+	return regionNone, x, y
+}
+
+// handleMouse selects notes, focuses panes, switches tabs, scrolls and
+// drags the split. The mouse is ignored while a dialog or overlay is open.
+func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.showHelp || m.deleting || m.creating || m.bulk != "" || m.unsaved != "" || len(m.conflicts) > 0 ||
+		(m.focus != "list" && m.focus != "content") {
+		return m, nil
+	}
+
+	if m.dragging {
+		switch msg.Action {
+		case tea.MouseActionMotion:
+			if m.layout().stacked {
+				m.setSplit((msg.Y + 1) * 100 / max(m.height-1, 1))
+			} else {
+				m.setSplit((msg.X + 1) * 100 / max(m.width, 1))
+			}
+		case tea.MouseActionRelease:
+			m.dragging = false
+		}
+		return m, nil
+	}
+
+	r, x, y := m.hit(msg.X, msg.Y)
+	if tea.MouseEvent(msg).IsWheel() {
+		up := msg.Button == tea.MouseButtonWheelUp
+		switch r {
+		case regionList:
+			if up {
+				m.moveCursor(m.cursor - 1)
+			} else {
+				m.moveCursor(m.cursor + 1)
+			}
+		case regionEditor:
+			t := &m.tabs[m.paneAt(x)]
+			if up {
+				t.editor.CursorUp()
+			} else {
+				t.editor.CursorDown()
+			}
+		}
+		return m, nil
+	}
+	if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft {
+		return m, nil
+	}
+
+	switch r {
+	case regionSplit:
+		m.dragging = true
+	case regionList:
+		if i, ok := m.itemAt(y); ok {
+			m.focusList()
+			m.moveCursor(i)
+		}
+	case regionTabs:
+		if i, ok := m.tabAt(x); ok {
+			m.switchTab(i)
+			m.selectNote(m.activeTab().id)
+		}
+	case regionHeader, regionEditor:
+		if m.sideBySide && m.paneAt(x) != m.active {
+			m.otherPane()
+		}
+		if m.activeTab().id != "" {
+			m.focus = "content"
+			m.activeTab().editor.Focus()
+		}
+	}
+	return m, nil
+}
+
+// focusList moves focus from the editor to the list.
+func (m *model) focusList() {
+	m.focus = "list"
+	m.activeTab().editor.Blur()
+	m.dismissSuggestion()
+}
+
+// itemAt returns the index of the list item at row y of the list pane.
+func (m model) itemAt(y int) (int, bool) {
+	// Below the top border are the list's title and status bar.
+	y -= 1 + lipgloss.Height(m.list.Styles.TitleBar.Render("")) + lipgloss.Height(m.list.Styles.StatusBar.Render(""))
+	d := newNoteDelegate(nil, m.groupBy, m.sortBy)
+	if y < 0 {
+		return 0, false
+	}
+	i := m.list.Paginator.Page*m.list.Paginator.PerPage + y/(d.Height()+d.Spacing())
+	return i, i < len(m.list.Items())
+}
+
+// tabAt returns the tab whose label is at column x of the tab bar.
+func (m model) tabAt(x int) (int, bool) {
+	pos := 0
+	for i := range m.tabs {
+		pos += lipgloss.Width(m.tabLabel(i))
+		if x < pos {
+			return i, true
+		}
+		pos++ // separator
+	}
+	return 0, false
+}
+
+// paneAt returns the tab shown at column x of the editor area.
+func (m model) paneAt(x int) int {
+	if !m.sideBySide {
+		return m.active
+	}
+	if x < m.layout().leftW+paneFrame {
+		return m.paneTabs[0]
+	}
+	return m.paneTabs[1]
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sortMode orders the notes of the list. Pinned notes always come first.
+type sortMode struct {
+	name string
+	less func(a, b noteListItem) bool
+}
+
+var sortModes = []sortMode{
+	{"created", func(a, b noteListItem) bool { return a.createdAt.Before(b.createdAt) }},
+	{"updated", func(a, b noteListItem) bool { return a.updatedAt.After(b.updatedAt) }},
+	{"title", func(a, b noteListItem) bool { return strings.ToLower(a.title) < strings.ToLower(b.title) }},
+}
+
+var groupModes = []string{"none", "date", "tag"}
+
+// dateBuckets are the groups of the date grouping, newest first.
+var dateBuckets = []string{"Today", "Yesterday", "This week", "This month", "Older"}
+
+const untagged = "Untagged"
+
+// exportedMsg reports notes written to a directory.
+type exportedMsg struct {
+	n, skipped int
+	dir        string
+	err        error
+}
+
+// noteGroup returns the group header of a note and its rank among groups.
+// Pinned notes form their own group ahead of the others.
+func noteGroup(item noteListItem, groupBy, sortBy int, now time.Time) (string, int) {
+	if item.pinned {
+		return "Pinned", -1
+	}
+	switch groupModes[groupBy] {
+	case "date":
+		t := item.createdAt
+		if sortModes[sortBy].name == "updated" {
+			t = item.updatedAt
+		}
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		i := 4
+		switch {
+		case !t.Before(today):
+			i = 0
+		case !t.Before(today.AddDate(0, 0, -1)):
+			i = 1
+		case !t.Before(today.AddDate(0, 0, -7)):
+			i = 2
+		case !t.Before(today.AddDate(0, -1, 0)):
+			i = 3
+		}
+		return dateBuckets[i], i
+	case "tag":
+		if len(item.tags) == 0 {
+			return untagged, 0
+		}
+		return "#" + item.tags[0], 0
+	}
+	return "", 0
+}
+
+// arrange sorts notes by group, then pinned first, then by the sort mode.
+func arrange(items []list.Item, groupBy, sortBy int) {
+	now := time.Now()
+	less := sortModes[sortBy].less
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].(noteListItem), items[j].(noteListItem)
+		ga, ra := noteGroup(a, groupBy, sortBy, now)
+		gb, rb := noteGroup(b, groupBy, sortBy, now)
+		switch {
+		case ra != rb:
+			return ra < rb
+		case ga != gb:
+			// Tag groups are alphabetical, with untagged notes last.
+			return gb == untagged || (ga != untagged && ga < gb)
+		case a.pinned != b.pinned:
+			return a.pinned
+		}
+		return less(a, b)
+	})
+}
+
+// markedItem shows a note selected for a bulk action.
+type markedItem struct{ noteListItem }
+
+func (i markedItem) Title() string { return "● " + i.noteListItem.Title() }
+
+// noteDelegate renders the notes of the list, marking selected ones. When
+// grouping, every item gets an extra line that holds the group header above
+// the first note of each group, so all items keep the same height.
+type noteDelegate struct {
+	list.DefaultDelegate
+	marked          map[string]bool
+	groupBy, sortBy int
+	now             time.Time
+}
+
+func newNoteDelegate(marked map[string]bool, groupBy, sortBy int) noteDelegate {
+	accent := lipgloss.Color(ui.theme.Accent)
+	d := list.NewDefaultDelegate()
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(accent).BorderForeground(accent)
+	d.Styles.SelectedDesc = d.Styles.SelectedDesc.Foreground(accent).BorderForeground(accent)
+	return noteDelegate{DefaultDelegate: d, marked: marked, groupBy: groupBy, sortBy: sortBy, now: time.Now()}
+}
+
+func (d noteDelegate) Height() int {
+	if d.groupBy == 0 {
+		return d.DefaultDelegate.Height()
+	}
+	return d.DefaultDelegate.Height() + 1
+}
+
+func (d noteDelegate) Spacing() int {
+	if d.groupBy == 0 {
+		return d.DefaultDelegate.Spacing()
+	}
+	return 0
+}
+
+func (d noteDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	note := item.(noteListItem)
+	if d.groupBy != 0 {
+		group, _ := noteGroup(note, d.groupBy, d.sortBy, d.now)
+		header := ""
+		if index == 0 {
+			header = group
+		} else if prev, _ := noteGroup(m.Items()[index-1].(noteListItem), d.groupBy, d.sortBy, d.now); prev != group {
+			header = group
+		}
+		fmt.Fprintln(w, ui.accent.MaxWidth(m.Width()).Render(header))
+	}
+	if d.marked[note.id] {
+		item = markedItem{note}
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
+}
+
+// refreshList re-sorts the list and redraws it for the current sort,
+// grouping and marks, keeping the cursor on the same note.
+func (m *model) refreshList() {
+	m.list.SetDelegate(newNoteDelegate(m.marked, m.groupBy, m.sortBy))
+	item, ok := m.list.SelectedItem().(noteListItem)
+	items := m.list.Items()
+	arrange(items, m.groupBy, m.sortBy)
+	m.list.SetItems(items)
+	if ok {
+		m.selectNote(item.id)
+	}
+	m.resize()
+}
+
+// cycleSort switches to the next sort mode.
+func (m *model) cycleSort() tea.Cmd {
+	m.sortBy = (m.sortBy + 1) % len(sortModes)
+	m.refreshList()
+	return m.notify("Sorted by "+sortModes[m.sortBy].name, false)
+}
+
+// cycleGroup switches to the next grouping.
+func (m *model) cycleGroup() tea.Cmd {
+	m.groupBy = (m.groupBy + 1) % len(groupModes)
+	m.refreshList()
+	if m.groupBy == 0 {
+		return m.notify("Not grouped", false)
+	}
+	return m.notify("Grouped by "+groupModes[m.groupBy], false)
+}
+
+// toggleMark selects or deselects the note under the cursor for bulk
+// actions and moves to the next note.
+func (m *model) toggleMark(item noteListItem) {
+	marked := make(map[string]bool, len(m.marked)+1)
+	for id := range m.marked {
+		marked[id] = true
+	}
+	if marked[item.id] {
+		delete(marked, item.id)
+	} else {
+		marked[item.id] = true
+	}
+	m.marked = marked
+	m.list.SetDelegate(newNoteDelegate(m.marked, m.groupBy, m.sortBy))
+	m.moveCursor(m.cursor + 1)
+}
+
+// clearMarks deselects all notes.
+func (m *model) clearMarks() {
+	m.marked = nil
+	m.list.SetDelegate(newNoteDelegate(nil, m.groupBy, m.sortBy))
+}
+
+// pruneMarks drops marks of notes that are no longer listed.
+func (m *model) pruneMarks() {
+	if len(m.marked) == 0 {
+		return
+	}
+	marked := map[string]bool{}
+	for _, it := range m.list.Items() {
+		if id := it.(noteListItem).id; m.marked[id] {
+			marked[id] = true
+		}
+	}
+	m.marked = marked
+	m.list.SetDelegate(newNoteDelegate(m.marked, m.groupBy, m.sortBy))
+}
+
+// targets returns the notes a bulk action applies to: the marked notes, or
+// else the one under the cursor.
+func (m model) targets() []noteListItem {
+	var notes []noteListItem
+	for _, it := range m.list.Items() {
+		if item := it.(noteListItem); m.marked[item.id] {
+			notes = append(notes, item)
+		}
+	}
+	if len(notes) == 0 {
+		if item, ok := m.list.SelectedItem().(noteListItem); ok {
+			notes = append(notes, item)
+		}
+	}
+	return notes
+}
+
+// openBulk opens the prompt of the tag or export action.
+func (m *model) openBulk(action string) tea.Cmd {
+	if len(m.targets()) == 0 {
+		return nil
+	}
+	m.bulk = action
+	m.bulkInput.Reset()
+	m.bulkInput.Placeholder = "work, -draft"
+	if action == "export" {
+		m.bulkInput.Placeholder = "."
+	}
+	return m.bulkInput.Focus()
+}
+
+// updateBulk handles keys in the tag and export prompts.
+func (m model) updateBulk(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Dialog.Cancel):
+		m.bulk = ""
+		m.bulkInput.Blur()
+		return m, nil
+	case key.Matches(msg, keys.Dialog.Confirm):
+		action, value, notes := m.bulk, strings.TrimSpace(m.bulkInput.Value()), m.targets()
+		m.bulk = ""
+		m.bulkInput.Blur()
+		if action == "export" {
+			if value == "" {
+				value = "."
+			}
+			return m, m.run(exportCmd(notes, value))
+		}
+		add, remove := parseTags(value)
+		if len(add)+len(remove) == 0 {
+			return m, nil
+		}
+		m.clearMarks()
+		return m, m.run(apiCmd("Tagging notes", func() error {
+			for _, n := range notes {
+				if err := api.tagNote(n.id, add, remove); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+
+	var cmd tea.Cmd
+	m.bulkInput, cmd = m.bulkInput.Update(msg)
+	return m, cmd
+}
+
+// parseTags reads a comma or space separated list of tags. Tags prefixed
+// with "-" are removed.
+func parseTags(value string) (add, remove []string) {
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if name, ok := strings.CutPrefix(tag, "-"); ok {
+			if name != "" {
+				remove = append(remove, name)
+			}
+			continue
+		}
+		add = append(add, strings.TrimPrefix(tag, "#"))
+	}
+	return add, remove
+}
+
+func (m model) bulkView() string {
+	n := len(m.targets())
+	label := fmt.Sprintf("Tag %d notes (-tag removes)", n)
+	if m.bulk == "export" {
+		label = fmt.Sprintf("Export %d notes to directory", n)
+	}
+	if n == 1 {
+		label = strings.Replace(label, "1 notes", "1 note", 1)
+	}
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		ui.dialog.Render(
+			lipgloss.JoinVertical(
+				lipgloss.Center,
+				label,
+				m.bulkInput.View(),
+				ui.muted.Render(shortHelp(keys.Dialog.Confirm, keys.Dialog.Cancel)),
+			),
+		),
+	)
+}
+
+func (m *model) handleExported(msg exportedMsg) tea.Cmd {
+	if msg.err != nil {
+		return m.notify(fmt.Sprintf("Export failed: %v", msg.err), true)
+	}
+	m.clearMarks()
+	text := fmt.Sprintf("Exported %d notes to %s", msg.n, msg.dir)
+	if msg.skipped > 0 {
+		text += fmt.Sprintf(", skipped %d that could not be decrypted", msg.skipped)
+	}
+	return m.notify(text, msg.skipped > 0)
+}
+
+// exportCmd writes each note to a Markdown file in dir, named after its
+// title. Existing files are never overwritten. Files are only readable by the
+// user, as they hold decrypted content for encrypted notes.
+func exportCmd(notes []noteListItem, dir string) tea.Cmd {
+	return func() tea.Msg {
+		msg := exportedMsg{dir: dir}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			msg.err = err
+			return msg
+		}
+		for _, n := range notes {
+			if n.locked {
+				msg.skipped++
+				continue
+			}
+			if err := exportNote(dir, n); err != nil {
+				msg.err = err
+				return msg
+			}
+			msg.n++
+		}
+		return msg
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+func exportNote(dir string, n noteListItem) error {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(n.title), "-"), "-.")
+	if name == "" {
+		name = n.id
+	}
+	content := fmt.Sprintf("# %s\n\n%s", n.title, n.content)
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, name+".md")
+		if i > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d.md", name, i))
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
+
+// newBulkInput returns the text input of the tag and export prompts.
+func newBulkInput() textinput.Model {
+	bi := textinput.New()
+	bi.CharLimit = 200
+	bi.Width = 40
+	return bi
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+// maxSearchResults is how many matches the search overlay lists.
+const maxSearchResults = 10
+
+// searchResult is a note matching the search query. Match indexes are byte
+// offsets into title and snippet.
+type searchResult struct {
+	id, title      string
+	titleMatches   []int
+	snippet        string
+	snippetMatches []int
+	fromServer     bool
+}
+
+// search is the overlay that finds notes by title and content. The loaded
+// notes are matched locally; when nothing matches, the server's search
+// endpoint is asked instead, if it has one.
+type search struct {
+	input     textinput.Model
+	results   []searchResult
+	selected  int
+	prevID    string
+	noServer  bool
+	searching bool
+}
+
+type searchMsg struct {
+	query   string
+	results []searchResult
+	err     error
+}
+
+// searchCandidate is one searchable line of a note: its title or a line of
+// its content.
+type searchCandidate struct {
+	item    noteListItem
+	text    string
+	isTitle bool
+}
+
+type searchCandidates []searchCandidate
+
+func (c searchCandidates) String(i int) string { return c[i].text }
+func (c searchCandidates) Len() int            { return len(c) }
+
+// matchNotes fuzzy-matches query against the titles and content lines of
+// items. Each note is listed once, with its best-scoring match.
+func matchNotes(query string, items []noteListItem) []searchResult {
+	var candidates searchCandidates
+	for _, item := range items {
+		candidates = append(candidates, searchCandidate{item: item, text: item.title, isTitle: true})
+		if item.locked {
+			continue
+		}
+		for _, line := range strings.Split(item.content, "\n") {
+			if strings.TrimSpace(line) != "" {
+				candidates = append(candidates, searchCandidate{item: item, text: line})
+			}
+		}
+	}
+
+	var results []searchResult
+	seen := map[string]bool{}
+	for _, match := range fuzzy.FindFrom(query, candidates) {
+		c := candidates[match.Index]
+		if seen[c.item.id] {
+			continue
+		}
+		seen[c.item.id] = true
+
+		r := searchResult{id: c.item.id, title: c.item.title}
+		if c.isTitle {
+			r.titleMatches = match.MatchedIndexes
+			r.snippet = firstLine(c.item.content)
+		} else {
+			r.snippet = c.text
+			r.snippetMatches = match.MatchedIndexes
+		}
+		if c.item.locked {
+			r.snippet = ""
+		}
+		results = append(results, r)
+		if len(results) == maxSearchResults {
+			break
+		}
+	}
+	return results
+}
+
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// searchServerCmd asks the server for notes containing query.
+func searchServerCmd(query string) tea.Cmd {
+	return func() tea.Msg {
+		notes, err := api.fetchSearch(query)
+		if err != nil {
+			return searchMsg{query: query, err: err}
+		}
+
+		var results []searchResult
+		for _, note := range notes {
+			openNote(&note)
+			results = append(results, searchResult{
+				id:         note.ID,
+				title:      note.Title,
+				snippet:    firstLine(note.Content),
+				fromServer: true,
+			})
+			if len(results) == maxSearchResults {
+				break
+			}
+		}
+		return searchMsg{query: query, results: results}
+	}
+}
+
+func (m *model) openSearch() tea.Cmd {
+	m.search.input.Reset()
+	m.search.results = nil
+	m.search.selected = 0
+	m.search.searching = false
+	// The server may have been upgraded, or the profile switched, since it
+	// last had no search endpoint.
+	m.search.noServer = false
+	m.search.prevID = ""
+	if item, ok := m.list.SelectedItem().(noteListItem); ok {
+		m.search.prevID = item.id
+	}
+	m.focus = "search"
+	return m.search.input.Focus()
+}
+
+func (m *model) closeSearch() {
+	m.focus = "list"
+	m.search.input.Blur()
+}
+
+// updateSearch handles keys while the search overlay is open. The list
+// follows the selected result as the query is typed.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Search.Cancel):
+		m.closeSearch()
+		m.jumpTo(m.search.prevID)
+		return m, nil
+	case key.Matches(msg, keys.Search.Open):
+		m.closeSearch()
+		if len(m.search.results) == 0 {
+			return m, nil
+		}
+		r := m.search.results[m.search.selected]
+		if !m.jumpTo(r.id) {
+			// Not in the current view; reload the main list with it selected.
+			m.view = 0
+			m.selectID = r.id
+			return m, m.run(loadNotesCmd(m.view))
+		}
+		return m, nil
+	case key.Matches(msg, keys.Search.Up):
+		if m.search.selected > 0 {
+			m.search.selected--
+			m.jumpTo(m.search.results[m.search.selected].id)
+		}
+		return m, nil
+	case key.Matches(msg, keys.Search.Down):
+		if m.search.selected < len(m.search.results)-1 {
+			m.search.selected++
+			m.jumpTo(m.search.results[m.search.selected].id)
+		}
+		return m, nil
+	}
+
+	prev := m.search.input.Value()
+	var cmd tea.Cmd
+	m.search.input, cmd = m.search.input.Update(msg)
+	query := m.search.input.Value()
+	if query == prev {
+		return m, cmd
+	}
+
+	m.search.selected = 0
+	m.search.searching = false
+	m.search.results = nil
+	if strings.TrimSpace(query) == "" {
+		m.jumpTo(m.search.prevID)
+		return m, cmd
+	}
+
+	var items []noteListItem
+	for _, it := range m.list.Items() {
+		items = append(items, it.(noteListItem))
+	}
+	m.search.results = matchNotes(query, items)
+	if len(m.search.results) > 0 {
+		m.jumpTo(m.search.results[0].id)
+		return m, cmd
+	}
+	if m.search.noServer {
+		return m, cmd
+	}
+	m.search.searching = true
+	return m, tea.Batch(cmd, m.run(searchServerCmd(query)))
+}
+
+// handleSearchResults shows server results if they are for the current
+// query and nothing matched locally in the meantime.
+func (m *model) handleSearchResults(msg searchMsg) tea.Cmd {
+	if msg.query != m.search.input.Value() || m.focus != "search" {
+		return nil
+	}
+	m.search.searching = false
+	if errors.Is(msg.err, errNotFound) {
+		m.search.noServer = true
+		return nil
+	}
+	if msg.err != nil {
+		return m.checkOnline(msg.err, "Searching")
+	}
+	if len(m.search.results) == 0 {
+		m.search.results = msg.results
+		m.search.selected = 0
+		if len(msg.results) > 0 {
+			m.jumpTo(msg.results[0].id)
+		}
+	}
+	return nil
+}
+
+// jumpTo selects the note with the given ID and shows it in the editor,
+// in a new tab if the active one has unsaved changes. It reports whether
+// the note is in the list.
+func (m *model) jumpTo(id string) bool {
+	for i, it := range m.list.Items() {
+		item := it.(noteListItem)
+		if item.id == id {
+			m.list.Select(i)
+			m.cursor = i
+			m.showNote(item, false)
+			return true
+		}
+	}
+	return false
+}
+
+// highlight renders the matched bytes of s in the match style.
+func highlight(s string, matches []int, base lipgloss.Style) string {
+	if len(matches) == 0 {
+		return base.Render(s)
+	}
+	matched := map[int]bool{}
+	for _, i := range matches {
+		matched[i] = true
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if matched[i] {
+			b.WriteString(ui.accent.Render(string(r)))
+		} else {
+			b.WriteString(base.Render(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// truncate shortens s to at most width runes, keeping the first match in
+// view.
+func truncate(s string, matches []int, width int) (string, []int) {
+	if utf8.RuneCountInString(s) <= width {
+		return s, matches
+	}
+	start := 0
+	if len(matches) > 0 && matches[0] > width/2 {
+		start = matches[0] - width/2
+		for start > 0 && !utf8.RuneStart(s[start]) {
+			start--
+		}
+	}
+	s = s[start:]
+	shifted := make([]int, 0, len(matches))
+	for _, i := range matches {
+		if i >= start {
+			shifted = append(shifted, i-start)
+		}
+	}
+	runes := []rune(s)
+	if len(runes) > width {
+		s = string(runes[:width-1]) + "…"
+	}
+	return s, shifted
+}
+
+func (m model) searchView() string {
+	width := min(max(m.width-20, 30), 80)
+
+	lines := []string{m.search.input.View(), ""}
+	switch {
+	case m.search.searching:
+		lines = append(lines, ui.muted.Render("Searching server…"))
+	case len(m.search.results) == 0 && m.search.input.Value() != "":
+		lines = append(lines, ui.muted.Render("No matches"))
+	}
+	for i, r := range m.search.results {
+		title, titleMatches := truncate(r.title, r.titleMatches, width-2)
+		line := highlight(title, titleMatches, lipgloss.NewStyle())
+		if i == m.search.selected {
+			line = ui.selected.Render(title)
+		}
+		if r.fromServer {
+			line += ui.muted.Render(" (server)")
+		}
+		lines = append(lines, line)
+		if r.snippet != "" {
+			snippet, snippetMatches := truncate(r.snippet, r.snippetMatches, width-4)
+			lines = append(lines, "  "+highlight(snippet, snippetMatches, ui.muted))
+		}
+	}
+	lines = append(lines, "", ui.muted.Render(shortHelp(keys.Search.Up, keys.Search.Down, keys.Search.Open, keys.Search.Cancel)))
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		ui.dialog.Width(width).Render(strings.Join(lines, "\n")),
+	)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// syncInterval is how often queued changes are synced while online.
+	syncInterval = 30 * time.Second
+	// reconnectDelay is the first wait before reconnecting; it doubles up
+	// to syncInterval while the server stays unreachable.
+	reconnectDelay = time.Second
+	toastDuration  = 4 * time.Second
+)
+
+// toast is a short-lived notification shown in the status bar.
+type toast struct {
+	id   int
+	text string
+	err  bool
+}
+
+type toastExpiredMsg struct{ id int }
+
+// after schedules the timers of the UI: toasts, syncs, autosaves and
+// completions. Tests replace it to keep timers from firing.
+var after = tea.Tick
+
+// run starts an API command and the spinner that shows it is in flight.
+func (m *model) run(cmd tea.Cmd) tea.Cmd {
+	m.pending++
+	if m.pending == 1 {
+		return tea.Batch(cmd, m.spinner.Tick)
+	}
+	return cmd
+}
+
+// done marks an API command started with run as finished.
+func (m *model) done() {
+	m.pending = max(m.pending-1, 0)
+}
+
+// notify shows a toast that disappears after a few seconds.
+func (m *model) notify(text string, isErr bool) tea.Cmd {
+	id := m.toast.id + 1
+	m.toast = toast{id: id, text: text, err: isErr}
+	return after(toastDuration, func(time.Time) tea.Msg { return toastExpiredMsg{id: id} })
+}
+
+// checkOnline reports a failed API call in the status bar and a toast. When
+// the server could not be reached it switches to offline mode and starts
+// reconnecting.
+func (m *model) checkOnline(err error, action string) tea.Cmd {
+	if err == nil {
+		return nil
+	}
+	if !isNetworkError(err) {
+		m.statusErr = fmt.Sprintf("%s failed: %v", action, err)
+		return m.notify(m.statusErr, true)
+	}
+	if m.offline {
+		return nil
+	}
+	m.offline = true
+	return tea.Batch(
+		m.notify("Server unreachable, working offline", true),
+		m.scheduleSync(reconnectDelay),
+	)
+}
+
+// scheduleSync replaces any pending sync tick with one after delay.
+func (m *model) scheduleSync(delay time.Duration) tea.Cmd {
+	m.syncGen++
+	m.syncDelay = delay
+	m.nextSync = time.Now().Add(delay)
+	gen := m.syncGen
+	return after(delay, func(time.Time) tea.Msg { return syncMsg{gen: gen} })
+}
+
+// statusLine shows background activity, connection state, the last error
+// and the active profile below the panes.
+func (m model) statusLine() string {
+	var parts []string
+	if m.pending > 0 {
+		parts = append(parts, m.spinner.View()+"working")
+	}
+
+	_, queued := local.state()
+	switch {
+	case m.offline && m.syncing:
+		parts = append(parts, ui.warn.Render("⚠ offline, reconnecting…"))
+	case m.offline:
+		wait := max(time.Until(m.nextSync).Round(time.Second), 0)
+		parts = append(parts, ui.warn.Render(fmt.Sprintf("⚠ offline, reconnecting in %s", wait)))
+	}
+	if len(m.marked) > 0 {
+		parts = append(parts, ui.accent.Render(fmt.Sprintf("%d selected", len(m.marked))))
+	}
+	if dirty := m.dirtyTabs(); dirty > 0 {
+		parts = append(parts, ui.warn.Render(fmt.Sprintf("%d unsaved", dirty)))
+	}
+	if queued > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued", queued))
+	}
+	if m.statusErr != "" {
+		parts = append(parts, ui.err.Render("✗ "+m.statusErr))
+	}
+	parts = append(parts, fmt.Sprintf("profile: %s • %s", api.profileName(), api.serverURL()))
+
+	line := ui.muted.Render(" " + strings.Join(parts, ui.muted.Render(" │ ")))
+	if m.toast.text != "" {
+		style := ui.toast
+		if m.toast.err {
+			style = ui.toastErr
+		}
+		line += "  " + style.Render(m.toast.text)
+	}
+	return line
+}
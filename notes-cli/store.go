@@ -0,0 +1,564 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// localIDPrefix marks notes created while offline that the server has not
+// assigned an ID to yet.
+const localIDPrefix = "local-"
+
+// errNotFound is returned when the server no longer has a note.
+var errNotFound = errors.New("note not found")
+
+// outboxEntry is a change made while the server was unreachable. Notes are
+// kept in their wire form, so encrypted notes stay encrypted on disk.
+type outboxEntry struct {
+	Op       string    `json:"op"` // "create", "update" or "delete"
+	Note     apiNote   `json:"note"`
+	Base     *apiNote  `json:"base,omitempty"`
+	QueuedAt time.Time `json:"queued_at"`
+	// Key is the Idempotency-Key of a create, the same as that of any
+	// attempt made before it was queued.
+	Key string `json:"key,omitempty"`
+}
+
+// conflict is an offline edit that could not be merged cleanly with the
+// server's copy. Its edit stays queued until the user picks a version, so
+// quitting or crashing in between loses nothing. Fields hold plaintext for
+// display, except server, the server's copy in wire form. A locked conflict
+// is between encrypted copies that cannot be opened: only the server's copy
+// can be kept, and the edit stays queued until the vault is unlocked.
+type conflict struct {
+	id, title    string
+	mine, theirs string
+	merged       string
+	locked       bool
+	server       apiNote
+}
+
+// noteCache is the local copy of the server's notes plus the outbox of
+// changes waiting to be synced. It is persisted as JSON in the user's cache
+// directory, one file per profile.
+type noteCache struct {
+	Notes    map[string]apiNote `json:"notes"`
+	Outbox   []outboxEntry      `json:"outbox"`
+	LastSync time.Time          `json:"last_sync"`
+
+	path    string
+	offline bool
+	// mu guards the cache; API commands run concurrently with the UI. It is
+	// never held across a request, so the UI can read the cache's state
+	// while the server is slow to answer.
+	mu sync.Mutex
+	// syncing serializes syncs, which replay the outbox one change at a
+	// time.
+	syncing sync.Mutex
+}
+
+func loadNoteCache() *noteCache {
+	c := &noteCache{Notes: map[string]apiNote{}}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		log.Printf("Error locating cache directory: %v", err)
+		return c
+	}
+	c.path = cachePath(dir, api.profileName(), api.serverURL())
+
+	// Caches used to be named after the profile alone; the first server the
+	// profile is used with after the upgrade is taken to be its own.
+	legacy := filepath.Join(dir, "notes-cli", api.profileName()+".json")
+	if _, err := os.Stat(c.path); os.IsNotExist(err) {
+		os.Rename(legacy, c.path)
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading cache: %v", err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		log.Printf("Error decoding cache: %v", err)
+	}
+	if c.Notes == nil {
+		c.Notes = map[string]apiNote{}
+	}
+	return c
+}
+
+// cachePath names the cache of a profile after its server as well, since
+// -server and NOTES_SERVER point a profile at another server, whose notes
+// and outbox must not mix with the profile's own.
+func cachePath(dir, profile, serverURL string) string {
+	return filepath.Join(dir, "notes-cli", cacheName(profile, serverURL)+".json")
+}
+
+// cacheName is the base name of the files kept for a profile and server.
+func cacheName(profile, serverURL string) string {
+	sum := sha256.Sum256([]byte(serverURL))
+	return profile + "-" + hex.EncodeToString(sum[:4])
+}
+
+func (c *noteCache) save() {
+	if c.path == "" {
+		return
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		log.Printf("Error encoding cache: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		log.Printf("Error creating cache directory: %v", err)
+		return
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("Error writing cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		log.Printf("Error writing cache: %v", err)
+	}
+}
+
+// state reports whether the server was unreachable on the last attempt and
+// how many changes are waiting to be synced.
+func (c *noteCache) state() (offline bool, queued int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offline, len(c.Outbox)
+}
+
+// refresh fetches a view from the server and returns it merged with queued
+// offline changes. When the server is unreachable the cached copy is
+// returned along with the error.
+func (c *noteCache) refresh(view noteView) ([]apiNote, error) {
+	notes, err := api.fetchNotes(view.query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.storeView(view.match, notes)
+	}
+	c.offline = isNetworkError(err)
+	return c.view(view.match), err
+}
+
+// storeView replaces the cached notes of a view with a fresh server list.
+// Notes that match the view but are missing from the list were deleted on
+// the server, unless they only exist locally.
+func (c *noteCache) storeView(match func(apiNote) bool, notes []apiNote) {
+	seen := map[string]bool{}
+	for _, n := range notes {
+		seen[n.ID] = true
+		if !c.pending(n.ID) {
+			c.Notes[n.ID] = n
+		}
+	}
+	for id, n := range c.Notes {
+		if !seen[id] && match(n) && !c.pending(id) {
+			delete(c.Notes, id)
+		}
+	}
+	c.save()
+}
+
+// view returns the cached notes matching a view, pinned notes first.
+func (c *noteCache) view(match func(apiNote) bool) []apiNote {
+	var notes []apiNote
+	for _, n := range c.Notes {
+		if match(n) {
+			notes = append(notes, n)
+		}
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].Pinned != notes[j].Pinned {
+			return notes[i].Pinned
+		}
+		return notes[i].CreatedAt.Before(notes[j].CreatedAt)
+	})
+	return notes
+}
+
+func (c *noteCache) pending(id string) bool {
+	for _, e := range c.Outbox {
+		if e.Note.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// queue records an offline change and applies it to the cached notes. It
+// returns the ID of the note, which is a local ID for creates.
+func (c *noteCache) queue(entry outboxEntry) string {
+	entry.QueuedAt = time.Now()
+	note := entry.Note
+	switch entry.Op {
+	case "create":
+		entry.Note.ID = newLocalID()
+		if entry.Note.CreatedAt.IsZero() {
+			entry.Note.CreatedAt = entry.QueuedAt
+		}
+		c.Notes[entry.Note.ID] = entry.Note
+	case "update":
+		// A note edited again is sent once, with the latest content, and
+		// merged against the version the first edit was based on.
+		for i, e := range c.Outbox {
+			if e.Op == "update" && e.Note.ID == note.ID {
+				c.Outbox[i].Note = note
+				if cached, ok := c.Notes[note.ID]; ok {
+					cached.Title, cached.Content, cached.Encrypted = note.Title, note.Content, note.Encrypted
+					c.Notes[note.ID] = cached
+				}
+				c.save()
+				return note.ID
+			}
+		}
+		if cached, ok := c.Notes[note.ID]; ok {
+			base := cached
+			entry.Base = &base
+			cached.Title, cached.Content, cached.Encrypted = note.Title, note.Content, note.Encrypted
+			c.Notes[note.ID] = cached
+		}
+	case "delete":
+		delete(c.Notes, note.ID)
+	}
+	c.Outbox = append(c.Outbox, entry)
+	c.save()
+	return entry.Note.ID
+}
+
+// create, update and remove send a change to the server, or queue it in the
+// outbox when the server is unreachable or earlier changes are still queued.
+// Only errors from the server rejecting the change are returned; create
+// also returns the new note's ID.
+func (c *noteCache) create(note apiNote) (string, error) {
+	// The note is sent as it would be queued, with the same key, so a
+	// create whose response was lost is not repeated by the next sync.
+	key := newIdempotencyKey()
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	if c.queued() == 0 {
+		created, err := api.postNote(note, key)
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.Notes[created.ID] = created
+			c.save()
+			return created.ID, nil
+		}
+		if !isNetworkError(err) {
+			return "", err
+		}
+		c.setOffline()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.queue(outboxEntry{Op: "create", Note: note, Key: key}), nil
+}
+
+func (c *noteCache) update(note apiNote) error {
+	if c.queued() == 0 {
+		updated, err := api.putNote(note)
+		if err == nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.Notes[updated.ID] = updated
+			c.save()
+			return nil
+		}
+		if !isNetworkError(err) {
+			return err
+		}
+		c.setOffline()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue(outboxEntry{Op: "update", Note: note})
+	return nil
+}
+
+func (c *noteCache) remove(id string) error {
+	if c.queued() == 0 {
+		err := api.removeNote(id)
+		if err == nil || errors.Is(err, errNotFound) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			delete(c.Notes, id)
+			c.save()
+			return nil
+		}
+		if !isNetworkError(err) {
+			return err
+		}
+		c.setOffline()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue(outboxEntry{Op: "delete", Note: apiNote{ID: id}})
+	return nil
+}
+
+// queued returns the number of changes waiting in the outbox.
+func (c *noteCache) queued() int {
+	_, n := c.state()
+	return n
+}
+
+func (c *noteCache) setOffline() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offline = true
+}
+
+// sync replays the outbox against the server and pulls notes changed since
+// the last sync. Offline edits to notes that also changed on the server are
+// merged three-way; edits that overlap are returned as conflicts and stay
+// queued until resolved. A change the server rejects stays queued too, and
+// its error is returned. Either way the changes after it wait, so they stay
+// in order.
+func (c *noteCache) sync() ([]conflict, error) {
+	c.syncing.Lock()
+	defer c.syncing.Unlock()
+
+	var conflicts []conflict
+	var rejected error
+	for {
+		c.mu.Lock()
+		if len(c.Outbox) == 0 {
+			c.mu.Unlock()
+			break
+		}
+		entry := c.Outbox[0]
+		c.mu.Unlock()
+
+		conf, err := c.replay(entry)
+		if conf != nil {
+			conflicts = append(conflicts, *conf)
+			break
+		}
+		if isNetworkError(err) {
+			c.setOffline()
+			return conflicts, nil
+		}
+		if err != nil {
+			rejected = fmt.Errorf("%s of %q: %w", entry.Op, entry.Note.Title, err)
+			break
+		}
+	}
+
+	c.mu.Lock()
+	since := c.LastSync
+	c.mu.Unlock()
+	changed, err := api.fetchNotes("?updated_since=" + url.QueryEscape(since.Format(time.RFC3339Nano)))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.offline = isNetworkError(err)
+		c.save()
+		return conflicts, rejected
+	}
+	c.offline = false
+	for _, n := range changed {
+		c.Notes[n.ID] = n
+		if n.UpdatedAt.After(c.LastSync) {
+			c.LastSync = n.UpdatedAt
+		}
+	}
+	c.save()
+	return conflicts, rejected
+}
+
+// replay sends the first change of the outbox to the server and takes it
+// out of the outbox once the server has it.
+func (c *noteCache) replay(entry outboxEntry) (*conflict, error) {
+	switch entry.Op {
+	case "create":
+		created, err := api.postNote(entry.Note, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.dequeue(entry, created)
+		delete(c.Notes, entry.Note.ID)
+		c.Notes[created.ID] = created
+		c.renameQueued(entry.Note.ID, created.ID)
+	case "update":
+		return c.replayUpdate(entry)
+	case "delete":
+		if err := api.removeNote(entry.Note.ID); err != nil && !errors.Is(err, errNotFound) {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.dequeue(entry, apiNote{})
+	}
+	return nil, nil
+}
+
+// dequeue takes a replayed change out of the outbox, unless it was resolved
+// in the meantime or, for an update, edited again while it was sent. The
+// later edit stays queued, based on result, the copy the server now has.
+// It reports whether entry was taken out.
+func (c *noteCache) dequeue(entry outboxEntry, result apiNote) bool {
+	if len(c.Outbox) == 0 {
+		return false
+	}
+	head := c.Outbox[0]
+	if head.Op != entry.Op || head.Note.ID != entry.Note.ID || !head.QueuedAt.Equal(entry.QueuedAt) {
+		return false
+	}
+	if head.Note.Title != entry.Note.Title || head.Note.Content != entry.Note.Content || head.Note.Encrypted != entry.Note.Encrypted {
+		c.Outbox[0].Base = &result
+		c.save()
+		return false
+	}
+	c.Outbox = c.Outbox[1:]
+	c.save()
+	return true
+}
+
+// resolve settles a conflict: the queued edit of the note is dropped and
+// note, if not nil, is written over the server's copy instead.
+func (c *noteCache) resolve(server apiNote, note *apiNote) error {
+	c.mu.Lock()
+	c.Outbox = slices.DeleteFunc(c.Outbox, func(e outboxEntry) bool {
+		return e.Op == "update" && e.Note.ID == server.ID
+	})
+	// Should note be queued, it is merged against the copy the user saw.
+	c.Notes[server.ID] = server
+	c.save()
+	c.mu.Unlock()
+
+	if note == nil {
+		return nil
+	}
+	return c.update(*note)
+}
+
+// renameQueued points queued changes to a note created offline at the ID the
+// server assigned to it.
+func (c *noteCache) renameQueued(localID, id string) {
+	for i := range c.Outbox {
+		if c.Outbox[i].Note.ID == localID {
+			c.Outbox[i].Note.ID = id
+		}
+		if b := c.Outbox[i].Base; b != nil && b.ID == localID {
+			b.ID = id
+		}
+	}
+}
+
+func (c *noteCache) replayUpdate(entry outboxEntry) (*conflict, error) {
+	server, changed, err := changedSince(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if !changed {
+		updated, err := api.putNote(entry.Note)
+		recreated := false
+		if errors.Is(err, errNotFound) {
+			// Deleted on the server while we were editing: keep our copy,
+			// under the new ID the server gives it.
+			updated, err = api.postNote(entry.Note, newIdempotencyKey())
+			recreated = err == nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.dequeue(entry, updated) {
+			c.Notes[updated.ID] = updated
+		}
+		if recreated {
+			delete(c.Notes, entry.Note.ID)
+			c.renameQueued(entry.Note.ID, updated.ID)
+		}
+		return nil, nil
+	}
+
+	base, mine, theirs := *entry.Base, entry.Note, server
+	if !openNote(&base) || !openNote(&mine) || !openNote(&theirs) {
+		return &conflict{id: server.ID, title: mine.Title, locked: true, server: server}, nil
+	}
+	merged, clean := merge3(base.Content, mine.Content, theirs.Content)
+	if !clean {
+		return &conflict{id: server.ID, title: mine.Title, mine: mine.Content, theirs: theirs.Content, merged: merged, server: server}, nil
+	}
+
+	title := mine.Title
+	if mine.Title == base.Title {
+		title = theirs.Title
+	}
+	result := apiNote{ID: server.ID, Title: title, Content: merged}
+	if err := sealNote(&result); err != nil {
+		return nil, err
+	}
+	updated, err := api.putNote(result)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dequeue(entry, updated) {
+		c.Notes[updated.ID] = updated
+	}
+	return nil, nil
+}
+
+// changedSince returns the server's copy of a queued update's note if it
+// changed after the version the update was based on.
+func changedSince(entry outboxEntry) (apiNote, bool, error) {
+	if entry.Base == nil {
+		return apiNote{}, false, nil
+	}
+	return fetchIfChanged(entry.Note.ID, entry.Base.UpdatedAt)
+}
+
+// fetchIfChanged returns the server's copy of a note if it was updated after
+// since. A note deleted on the server counts as unchanged; writing it then
+// recreates it.
+func fetchIfChanged(id string, since time.Time) (apiNote, bool, error) {
+	note, err := api.fetchNote(id)
+	if errors.Is(err, errNotFound) {
+		return apiNote{}, false, nil
+	}
+	if err != nil {
+		return apiNote{}, false, err
+	}
+	return note, note.UpdatedAt.After(since), nil
+}
+
+func newLocalID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return localIDPrefix + hex.EncodeToString(b)
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCache returns an empty, unsaved cache of srv's notes, with retries of
+// unreachable requests made at once and only once.
+func newCache(t *testing.T, srv *fakeServer) *noteCache {
+	t.Helper()
+	c, err := newAPIClient("test", profile{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delays := retryDelays
+	t.Cleanup(func() { retryDelays = delays })
+	api, retryDelays = c, []time.Duration{0}
+	return &noteCache{Notes: map[string]apiNote{}}
+}
+
+func TestCreateRetried(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := newCache(t, srv)
+
+	// The retry carries the same Idempotency-Key and gets the note created
+	// by the first attempt.
+	srv.loseCreates(1)
+	id, err := cache.create(apiNote{Title: "Ideas"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "note-1" || srv.count() != 1 || len(cache.Outbox) != 0 {
+		t.Errorf("created %q, %d notes on the server, %d queued", id, srv.count(), len(cache.Outbox))
+	}
+}
+
+func TestQueuedCreateSynced(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := newCache(t, srv)
+
+	// Both attempts lose their response, so the note is queued; the sync
+	// then sends it with the key of those attempts.
+	srv.loseCreates(2)
+	id, err := cache.create(apiNote{Title: "Ideas"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.Outbox) != 1 || cache.Outbox[0].Key == "" {
+		t.Fatalf("outbox %+v", cache.Outbox)
+	}
+	if _, err := cache.sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.count() != 1 || len(cache.Outbox) != 0 {
+		t.Errorf("%d notes on the server, %d queued after sync", srv.count(), len(cache.Outbox))
+	}
+	if _, ok := cache.Notes[id]; ok {
+		t.Errorf("local note %s still cached", id)
+	}
+	if n, ok := cache.Notes["note-1"]; !ok || n.Title != "Ideas" {
+		t.Errorf("cached notes %+v", cache.Notes)
+	}
+}
+
+func TestLockedConflictStaysQueued(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := newCache(t, srv)
+	sealed := newVault("secret", false)
+	note := apiNote{Title: "Diary", Content: "Dear diary", Encrypted: true}
+	if err := sealed.seal(&note); err != nil {
+		t.Fatal(err)
+	}
+	// The note changed on the server after the offline edit was based on it,
+	// and without a passphrase neither copy can be merged.
+	srv.mu.Lock()
+	base := srv.create(note)
+	mine, theirs := base, base
+	theirs.Pinned, theirs.UpdatedAt = true, srv.tick()
+	srv.notes[base.ID] = theirs
+	srv.mu.Unlock()
+	cache.Outbox = []outboxEntry{
+		{Op: "update", Note: mine, Base: &base},
+		{Op: "delete", Note: apiNote{ID: base.ID}},
+	}
+	conflicts, err := cache.sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || !conflicts[0].locked || conflicts[0].mine != "" {
+		t.Errorf("conflicts %+v", conflicts)
+	}
+	if len(cache.Outbox) != 2 {
+		t.Errorf("%d changes queued, want 2", len(cache.Outbox))
+	}
+	if _, ok := srv.note(base.ID); !ok {
+		t.Error("queued delete ran before the locked edit")
+	}
+}
+
+func TestConflictStaysQueued(t *testing.T) {
+	for _, keepServer := range []bool{false, true} {
+		srv := newFakeServer(t, apiNote{Title: "Groceries", Content: "Milk"})
+		cache := newCache(t, srv)
+		defer func(c *noteCache) { local = c }(local)
+		local = cache
+
+		// Two offline edits of the note are sent as one, which overlaps
+		// with a change made on the server in the meantime.
+		base, _ := srv.note("note-1")
+		cache.Notes[base.ID] = base
+		cache.queue(outboxEntry{Op: "update", Note: apiNote{ID: base.ID, Title: "Groceries", Content: "Oat milk"}})
+		cache.queue(outboxEntry{Op: "update", Note: apiNote{ID: base.ID, Title: "Groceries", Content: "Oat milk\nEggs"}})
+		srv.mu.Lock()
+		theirs := srv.notes[base.ID]
+		theirs.Content, theirs.UpdatedAt = "Soy milk", srv.tick()
+		srv.notes[base.ID] = theirs
+		srv.mu.Unlock()
+
+		conflicts, err := cache.sync()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conflicts) != 1 || conflicts[0].mine != "Oat milk\nEggs" || conflicts[0].theirs != "Soy milk" {
+			t.Fatalf("conflicts %+v", conflicts)
+		}
+		// Until it is resolved, the edit survives in the outbox.
+		if len(cache.Outbox) != 1 {
+			t.Fatalf("%d changes queued, want 1", len(cache.Outbox))
+		}
+
+		if err := resolveNote(conflicts[0], conflicts[0].mine, keepServer); err != nil {
+			t.Fatal(err)
+		}
+		want := "Oat milk\nEggs"
+		if keepServer {
+			want = "Soy milk"
+		}
+		if n, _ := srv.note(base.ID); n.Content != want || len(cache.Outbox) != 0 || cache.Notes[base.ID].Content != want {
+			t.Errorf("keep server %v: stored %q, cached %q, %d queued",
+				keepServer, n.Content, cache.Notes[base.ID].Content, len(cache.Outbox))
+		}
+	}
+}
+
+func TestRejectedChangeStaysQueued(t *testing.T) {
+	srv := newFakeServer(t, apiNote{Title: "Groceries", Content: "Milk"})
+	cache := newCache(t, srv)
+	cache.Outbox = []outboxEntry{
+		{Op: "update", Note: apiNote{ID: "note-1", Title: "Groceries", Content: "Milk\nEggs"}},
+		{Op: "delete", Note: apiNote{ID: "note-1"}},
+	}
+
+	srv.setRefuse(http.StatusInternalServerError)
+	if _, err := cache.sync(); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("sync error %v", err)
+	}
+	if len(cache.Outbox) != 2 {
+		t.Fatalf("%d changes queued, want 2", len(cache.Outbox))
+	}
+
+	// Once the server takes them, both changes are made in order.
+	srv.setRefuse(0)
+	if _, err := cache.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.Outbox) != 0 || srv.count() != 0 {
+		t.Errorf("%d queued, %d notes on the server", len(cache.Outbox), srv.count())
+	}
+}
+
+func TestUpdateOfDeletedNote(t *testing.T) {
+	srv := newFakeServer(t)
+	cache := newCache(t, srv)
+
+	// The note was deleted on the server while two edits were queued; the
+	// first brings it back and the second must update that copy.
+	cache.Outbox = []outboxEntry{
+		{Op: "update", Note: apiNote{ID: "gone", Title: "Ideas", Content: "Tabs"}},
+		{Op: "update", Note: apiNote{ID: "gone", Title: "Ideas", Content: "Tabs and splits"}},
+	}
+	if _, err := cache.sync(); err != nil {
+		t.Fatal(err)
+	}
+	n, ok := srv.note("note-1")
+	if srv.count() != 1 || !ok || n.Content != "Tabs and splits" {
+		t.Errorf("%d notes on the server, note-1 %+v", srv.count(), n)
+	}
+	if _, ok := cache.Notes["gone"]; ok {
+		t.Error("old ID still cached")
+	}
+}
+
+func TestStateWhileServerSlow(t *testing.T) {
+	srv := newFakeServer(t, apiNote{Title: "Groceries"})
+	cache := newCache(t, srv)
+
+	// The status bar reads the cache's state on every render, so it must
+	// not wait for a request in flight.
+	stalled := srv.stallLists()
+	done := make(chan error)
+	go func() {
+		_, err := cache.refresh(noteViews[0])
+		done <- err
+	}()
+	wait := <-stalled
+
+	state := make(chan int)
+	go func() {
+		_, queued := cache.state()
+		state <- queued
+	}()
+	select {
+	case <-state:
+	case <-time.After(time.Second):
+		t.Error("state waited for the request")
+	}
+	close(wait)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachePerServer(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	caches := map[string]*noteCache{}
+	for _, url := range []string{"http://a.test", "http://b.test"} {
+		c, err := newAPIClient("default", profile{URL: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		api = c
+		caches[url] = loadNoteCache()
+	}
+	a, b := caches["http://a.test"], caches["http://b.test"]
+	if a.path == b.path {
+		t.Fatalf("both servers cached in %s", a.path)
+	}
+
+	a.queue(outboxEntry{Op: "delete", Note: apiNote{ID: "note-1"}})
+	api, _ = newAPIClient("default", profile{URL: "http://b.test"})
+	if reloaded := loadNoteCache(); len(reloaded.Outbox) != 0 {
+		t.Errorf("server b sees the outbox of server a: %+v", reloaded.Outbox)
+	}
+}
+
+func TestLegacyCacheAdopted(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	legacy := filepath.Join(dir, "notes-cli", "default.json")
+	os.MkdirAll(filepath.Dir(legacy), 0o700)
+	if err := os.WriteFile(legacy, []byte(`{"outbox":[{"op":"delete","note":{"id":"note-1"}}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	api, _ = newAPIClient("default", profile{URL: "http://a.test"})
+	if c := loadNoteCache(); len(c.Outbox) != 1 {
+		t.Errorf("outbox %+v", c.Outbox)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy cache left behind: %v", err)
+	}
+}
+
+func TestEditMergedWithServerCopy(t *testing.T) {
+	srv := newFakeServer(t, apiNote{Title: "Groceries", Content: "Milk\nEggs\nBread"})
+	cache := newCache(t, srv)
+	defer func(c *noteCache) { local = c }(local)
+	local = cache
+
+	loaded, err := api.fetchNote("note-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := newNoteListItem(loaded)
+
+	// Unchanged on the server, the edit is saved as is.
+	msg := saveEditedCmd(item, "Oat milk\nEggs\nBread")().(editorSavedMsg)
+	if msg.err != nil || msg.warn {
+		t.Fatalf("first save: %+v", msg)
+	}
+
+	// The server copy has changed since the item was loaded, so the next
+	// edit is merged into it.
+	msg = saveEditedCmd(item, "Milk\nEggs\nBread\nButter")().(editorSavedMsg)
+	if msg.err != nil || !msg.warn || len(msg.conflicts) != 0 {
+		t.Fatalf("second save: %+v", msg)
+	}
+	if n, _ := srv.note("note-1"); n.Content != "Oat milk\nEggs\nBread\nButter" {
+		t.Errorf("stored %q", n.Content)
+	}
+}
+
+func TestEditLockedServerCopy(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	notesVault = newVault("right", false)
+	t.Cleanup(func() { notesVault = nil })
+	note := apiNote{Title: "Diary", Content: "Dear diary"}
+	if err := notesVault.seal(&note); err != nil {
+		t.Fatal(err)
+	}
+	srv := newFakeServer(t, note)
+	cache := newCache(t, srv)
+	defer func(c *noteCache) { local = c }(local)
+	local = cache
+
+	loaded, err := api.fetchNote("note-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := newNoteListItem(loaded)
+
+	// The note is rewritten with another passphrase while it is edited.
+	other := apiNote{Content: "Dear diary, rewritten"}
+	if err := newVault("other", false).seal(&other); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	stored := srv.notes["note-1"]
+	stored.Content, stored.UpdatedAt = other.Content, srv.tick()
+	srv.notes["note-1"] = stored
+	srv.mu.Unlock()
+
+	msg := saveEditedCmd(item, "Dear diary, edited")().(editorSavedMsg)
+	if msg.err != nil || !msg.warn || len(msg.conflicts) != 0 {
+		t.Fatalf("save: %+v", msg)
+	}
+	if n, _ := srv.note("note-1"); n.Content != other.Content {
+		t.Error("the undecryptable server copy was overwritten")
+	}
+
+	// The edit is kept in a file only the user can read.
+	path := msg.notice[strings.LastIndex(msg.notice, " ")+1:]
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("notice %q: %v", msg.notice, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "Dear diary, edited" || info.Mode().Perm() != 0o600 {
+		t.Errorf("kept %q with mode %v", data, info.Mode().Perm())
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// tab is a note open in the editor. saved is the content as last loaded or
+// saved; the tab is dirty while the editor holds anything else.
+type tab struct {
+	id, title string
+	saved     string
+	locked    bool
+	editor    textarea.Model
+	// edits counts changes typed into the editor, to debounce autosave.
+	edits int
+}
+
+func (t tab) dirty() bool {
+	return t.id != "" && t.editor.Value() != t.saved
+}
+
+// tabSavedMsg reports that a tab's content was saved.
+type tabSavedMsg struct {
+	id, content string
+	err         error
+}
+
+func newEditor() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Loading notes…"
+	ta.ShowLineNumbers = true
+	return ta
+}
+
+// activeTab returns the tab shown in the focused editor pane.
+func (m *model) activeTab() *tab {
+	return &m.tabs[m.active]
+}
+
+// openTab returns the index of the tab holding a note, or -1.
+func (m *model) openTab(id string) int {
+	for i, t := range m.tabs {
+		if t.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// showNote shows a note in the editor. A note that is already open is
+// switched to; otherwise it replaces the active tab's note, or opens in a
+// new tab if newTab is set or the active tab has unsaved changes.
+func (m *model) showNote(item noteListItem, newTab bool) {
+	if i := m.openTab(item.id); i >= 0 {
+		m.switchTab(i)
+		return
+	}
+	if newTab || m.activeTab().dirty() {
+		t := tab{editor: newEditor()}
+		m.tabs = append(m.tabs, t)
+		m.switchTab(len(m.tabs) - 1)
+		m.resize()
+	}
+	t := m.activeTab()
+	t.id, t.title, t.saved, t.locked = item.id, item.title, item.content, item.locked
+	t.editor.Placeholder = "This note is empty"
+	t.editor.SetValue(item.content)
+}
+
+// switchTab makes tab i the active one, keeping editor focus where it was.
+func (m *model) switchTab(i int) {
+	focused := m.activeTab().editor.Focused()
+	m.activeTab().editor.Blur()
+	m.active = i
+	if m.sideBySide {
+		// Switching to the tab in the other pane swaps the two panes.
+		if m.paneTabs[1-m.pane] == i {
+			m.paneTabs[1-m.pane] = m.paneTabs[m.pane]
+		}
+		m.paneTabs[m.pane] = i
+	}
+	if focused {
+		m.activeTab().editor.Focus()
+	}
+}
+
+// cycleTab moves to the next or previous tab and selects its note.
+func (m *model) cycleTab(delta int) {
+	if len(m.tabs) < 2 {
+		return
+	}
+	m.switchTab((m.active + delta + len(m.tabs)) % len(m.tabs))
+	m.selectNote(m.activeTab().id)
+}
+
+// closeTab closes the active tab, first asking what to do with unsaved
+// changes.
+func (m *model) closeTab() {
+	if m.activeTab().dirty() {
+		m.unsaved = "close"
+		return
+	}
+	m.removeTab(m.active)
+	m.selectNote(m.activeTab().id)
+}
+
+// removeTab closes tab i, discarding unsaved changes. The last tab is
+// emptied instead.
+func (m *model) removeTab(i int) {
+	defer m.writeJournal()
+	focused := m.activeTab().editor.Focused()
+	if len(m.tabs) == 1 {
+		t := m.activeTab()
+		t.id, t.title, t.saved, t.locked = "", "", "", false
+		t.editor.Reset()
+		return
+	}
+
+	m.tabs = append(m.tabs[:i], m.tabs[i+1:]...)
+	fix := func(j int) int {
+		if j > i || j == len(m.tabs) {
+			return j - 1
+		}
+		return j
+	}
+	m.active = fix(m.active)
+	m.paneTabs = [2]int{fix(m.paneTabs[0]), fix(m.paneTabs[1])}
+	m.paneTabs[m.pane] = m.active
+	if m.paneTabs[1-m.pane] == m.active {
+		m.paneTabs[1-m.pane] = (m.active + 1) % len(m.tabs)
+	}
+	if len(m.tabs) < 2 {
+		m.sideBySide = false
+	}
+	if focused {
+		m.activeTab().editor.Focus()
+	}
+	m.resize()
+}
+
+// toggleSplit shows the active tab and another one side by side.
+func (m *model) toggleSplit() tea.Cmd {
+	if m.sideBySide {
+		m.sideBySide = false
+		m.resize()
+		return nil
+	}
+	if len(m.tabs) < 2 {
+		return m.notify("Open another note in a tab to split the editor", true)
+	}
+	m.sideBySide = true
+	m.pane = 0
+	m.paneTabs = [2]int{m.active, (m.active + 1) % len(m.tabs)}
+	m.resize()
+	return nil
+}
+
+// otherPane moves focus to the other side of the split.
+func (m *model) otherPane() {
+	if !m.sideBySide {
+		return
+	}
+	focused := m.activeTab().editor.Focused()
+	m.activeTab().editor.Blur()
+	m.pane = 1 - m.pane
+	m.active = m.paneTabs[m.pane]
+	if focused {
+		m.activeTab().editor.Focus()
+	}
+	m.selectNote(m.activeTab().id)
+}
+
+// saveTab writes a tab's content to the server.
+func (m *model) saveTab(t tab) tea.Cmd {
+	if t.locked {
+		return m.notify("Refusing to overwrite a note that could not be decrypted", true)
+	}
+	id, title, content := t.id, t.title, t.editor.Value()
+	return m.run(func() tea.Msg {
+		return tabSavedMsg{id: id, content: content, err: updateNote(id, title, content)}
+	})
+}
+
+// saveAll saves every tab with unsaved changes.
+func (m *model) saveAll() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, t := range m.tabs {
+		if t.dirty() {
+			cmds = append(cmds, m.saveTab(t))
+		}
+	}
+	if len(cmds) == 0 {
+		return m.notify("No unsaved changes", false)
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleTabSaved records a save. A tab being closed is closed once saved,
+// and the program quits once every tab is saved if that was asked for.
+func (m *model) handleTabSaved(msg tabSavedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.closing, m.quitting = "", false
+		return m.checkOnline(msg.err, "Saving note")
+	}
+	if i := m.openTab(msg.id); i >= 0 {
+		m.tabs[i].saved = msg.content
+		if m.closing == msg.id {
+			m.closing = ""
+			m.removeTab(i)
+			m.selectNote(m.activeTab().id)
+		}
+	}
+	if m.quitting && m.dirtyTabs() == 0 {
+		return m.quitCmd()
+	}
+	m.writeJournal()
+	return m.run(loadNotesCmd(m.view))
+}
+
+// refreshTabs updates open tabs from a fresh list. Content is only
+// replaced in tabs without unsaved changes, and not in the active editor
+// while it is being typed in.
+func (m *model) refreshTabs(items []noteListItem) {
+	byID := map[string]noteListItem{}
+	for _, item := range items {
+		byID[item.id] = item
+	}
+	for i := range m.tabs {
+		t := &m.tabs[i]
+		item, ok := byID[t.id]
+		if !ok {
+			continue
+		}
+		t.title, t.locked = item.title, item.locked
+		if t.dirty() || (i == m.active && m.focus == "content") {
+			continue
+		}
+		t.saved = item.content
+		if t.editor.Value() != item.content {
+			t.editor.SetValue(item.content)
+		}
+	}
+}
+
+// dirtyTabs counts tabs with unsaved changes.
+func (m model) dirtyTabs() int {
+	n := 0
+	for _, t := range m.tabs {
+		if t.dirty() {
+			n++
+		}
+	}
+	return n
+}
+
+// tabLabel is the text of tab i in the tab bar.
+func (m model) tabLabel(i int) string {
+	t := m.tabs[i]
+	title := t.title
+	if t.id == "" {
+		title = "empty"
+	}
+	if t.dirty() {
+		return fmt.Sprintf(" %d ● %s ", i+1, title)
+	}
+	return fmt.Sprintf(" %d %s ", i+1, title)
+}
+
+// tabBar lists the open tabs, marking the active one and unsaved changes.
+func (m model) tabBar(width int) string {
+	var parts []string
+	for i := range m.tabs {
+		label := m.tabLabel(i)
+		switch {
+		case i == m.active:
+			label = ui.selected.Render(label)
+		case m.sideBySide && i == m.paneTabs[1-m.pane]:
+			label = ui.accent.Render(label)
+		default:
+			label = ui.muted.Render(label)
+		}
+		parts = append(parts, label)
+	}
+	return lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(parts, ui.muted.Render("│")))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme is the color palette of the UI. Colors are ANSI numbers ("213") or
+// hex codes ("#ff79c6"); an empty border keeps the terminal's default.
+type theme struct {
+	Base      string `json:"base,omitempty" toml:"base"`
+	Accent    string `json:"accent,omitempty" toml:"accent"`
+	Muted     string `json:"muted,omitempty" toml:"muted"`
+	Border    string `json:"border,omitempty" toml:"border"`
+	Error     string `json:"error,omitempty" toml:"error"`
+	Warning   string `json:"warning,omitempty" toml:"warning"`
+	Success   string `json:"success,omitempty" toml:"success"`
+	ToastText string `json:"toast_text,omitempty" toml:"toast_text"`
+}
+
+var builtinThemes = map[string]theme{
+	"dark": {
+		Accent:    "213",
+		Muted:     "240",
+		Error:     "203",
+		Warning:   "214",
+		Success:   "42",
+		ToastText: "0",
+	},
+	"light": {
+		Accent:    "127",
+		Muted:     "244",
+		Border:    "250",
+		Error:     "160",
+		Warning:   "130",
+		Success:   "28",
+		ToastText: "15",
+	},
+	"high-contrast": {
+		Accent:    "226",
+		Muted:     "252",
+		Border:    "15",
+		Error:     "196",
+		Warning:   "208",
+		Success:   "46",
+		ToastText: "0",
+	},
+}
+
+// loadTheme returns a built-in theme, or a user theme read from
+// $XDG_CONFIG_HOME/notes-cli/themes/<name>.toml or <name>.json. A user theme
+// only needs the colors it changes; the rest come from its base theme,
+// which defaults to dark. NOTES_THEME overrides the configured name.
+func loadTheme(name string) (theme, error) {
+	if env := os.Getenv("NOTES_THEME"); env != "" {
+		name = env
+	}
+	if name == "" {
+		name = "dark"
+	}
+	if t, ok := builtinThemes[name]; ok {
+		return t, nil
+	}
+
+	var t theme
+	path, err := themePath(name)
+	if err != nil {
+		return t, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	if strings.HasSuffix(path, ".toml") {
+		err = toml.Unmarshal(data, &t)
+	} else {
+		err = json.Unmarshal(data, &t)
+	}
+	if err != nil {
+		return t, fmt.Errorf("invalid theme %s: %w", path, err)
+	}
+
+	if t.Base == "" {
+		t.Base = "dark"
+	}
+	base, ok := builtinThemes[t.Base]
+	if !ok {
+		return t, fmt.Errorf("theme %s: unknown base theme %q", path, t.Base)
+	}
+	fields := []struct{ value, base *string }{
+		{&t.Accent, &base.Accent},
+		{&t.Muted, &base.Muted},
+		{&t.Border, &base.Border},
+		{&t.Error, &base.Error},
+		{&t.Warning, &base.Warning},
+		{&t.Success, &base.Success},
+		{&t.ToastText, &base.ToastText},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = *f.base
+		}
+	}
+	return t, nil
+}
+
+func themePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range []string{".toml", ".json"} {
+		path := filepath.Join(dir, "notes-cli", "themes", name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("unknown theme %q", name)
+}
+
+// styles are the lipgloss styles derived from a theme. Views copy and size
+// them as needed; they are never changed after setup.
+type styles struct {
+	theme             theme
+	pane, paneFocused lipgloss.Style
+	dialog            lipgloss.Style
+	muted, accent     lipgloss.Style
+	err, warn, ok     lipgloss.Style
+	selected, today   lipgloss.Style
+	toast, toastErr   lipgloss.Style
+}
+
+// ui holds the styles of the active theme, set up by setup.
+var ui = newStyles(builtinThemes["dark"])
+
+func newStyles(t theme) styles {
+	color := func(c string) lipgloss.TerminalColor {
+		if c == "" {
+			return lipgloss.NoColor{}
+		}
+		return lipgloss.Color(c)
+	}
+	pane := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(color(t.Border))
+	toast := lipgloss.NewStyle().Padding(0, 1).Foreground(color(t.ToastText)).Background(color(t.Success))
+
+	return styles{
+		theme:       t,
+		pane:        pane,
+		paneFocused: pane.BorderForeground(color(t.Accent)),
+		dialog:      pane.BorderForeground(color(t.Accent)).Padding(1, 2),
+		muted:       lipgloss.NewStyle().Foreground(color(t.Muted)),
+		accent:      lipgloss.NewStyle().Foreground(color(t.Accent)).Bold(true),
+		err:         lipgloss.NewStyle().Foreground(color(t.Error)),
+		warn:        lipgloss.NewStyle().Foreground(color(t.Warning)),
+		ok:          lipgloss.NewStyle().Foreground(color(t.Success)),
+		selected:    lipgloss.NewStyle().Reverse(true),
+		today:       lipgloss.NewStyle().Underline(true),
+		toast:       toast,
+		toastErr:    toast.Background(color(t.Error)),
+	}
+}
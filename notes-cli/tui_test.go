@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func seedNotes(t *testing.T) *fakeServer {
+	return newFakeServer(t,
+		apiNote{Title: "Groceries", Content: "Milk\nEggs\nBread", Tags: []string{"home"}},
+		apiNote{Title: "Standup", Content: "Yesterday: reviews\nToday: release notes", Pinned: true},
+		apiNote{Title: "Reading list", Content: "The Go Programming Language"},
+	)
+}
+
+func TestListView(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+	tm.golden("list")
+
+	tm.press("down")
+	tm.golden("list_down")
+}
+
+func TestEditNote(t *testing.T) {
+	srv := seedNotes(t)
+	tm := newTUI(t, srv)
+
+	tm.press("down", "enter", "end")
+	tm.typeText(", coffee")
+	tm.golden("edit_dirty")
+
+	tm.press("ctrl+b")
+	tm.golden("edit_saved")
+	if n, _ := srv.note("note-1"); n.Content != "Milk\nEggs\nBread, coffee" {
+		t.Errorf("saved content = %q", n.Content)
+	}
+}
+
+func TestNewNote(t *testing.T) {
+	srv := seedNotes(t)
+	tm := newTUI(t, srv)
+
+	tm.press("ctrl+n")
+	tm.typeText("Ideas")
+	tm.golden("new_dialog")
+
+	tm.press("enter")
+	tm.golden("new_created")
+	if srv.count() != 4 {
+		t.Errorf("server has %d notes, want 4", srv.count())
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+
+	tm.press("/")
+	tm.typeText("release")
+	tm.golden("search")
+
+	tm.press("enter")
+	tm.golden("search_opened")
+}
+
+func TestSearchServerAskedAgain(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+
+	// A server without search is not asked again while the overlay is
+	// open, but is once it is reopened.
+	tm.press("/")
+	tm.typeText("zebra")
+	tm.send(searchMsg{query: "zebra", err: errNotFound})
+	if !tm.m.search.noServer {
+		t.Fatal("server search still on after a 404")
+	}
+	tm.press("esc", "/")
+	if tm.m.search.noServer {
+		t.Error("server search still off after reopening")
+	}
+}
+
+func TestQuitWithConflict(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+	tm.m.addConflicts([]conflict{{id: "note-1", title: "Groceries", mine: "Oat milk", theirs: "Milk"}})
+
+	// Quitting asks first, and asks once.
+	tm.press("ctrl+c")
+	if tm.quit || tm.m.unsaved != "quit" {
+		t.Fatal("quit without asking about the conflict")
+	}
+	tm.golden("quit_conflict")
+	tm.press("ctrl+c")
+	if !tm.quit {
+		t.Error("quitting again did not quit")
+	}
+}
+
+func TestDeleteNote(t *testing.T) {
+	srv := seedNotes(t)
+	tm := newTUI(t, srv)
+
+	tm.press("d")
+	tm.golden("delete_confirm")
+
+	tm.press("y")
+	tm.golden("deleted")
+	if _, ok := srv.note("note-2"); ok {
+		t.Error("pinned note still on the server")
+	}
+}
+
+func TestHelp(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+
+	tm.press("?")
+	tm.golden("help")
+
+	tm.press("esc")
+	tm.golden("list")
+}
+
+func TestQuitWithUnsavedChanges(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+
+	tm.press("enter")
+	tm.typeText("Draft: ")
+	tm.press("ctrl+c")
+	tm.golden("quit_unsaved")
+	if tm.quit {
+		t.Fatal("quit without asking about unsaved changes")
+	}
+
+	tm.press("d")
+	if !tm.quit {
+		t.Error("discarding changes did not quit")
+	}
+}
+
+func TestJournalPerServer(t *testing.T) {
+	tm := newTUI(t, seedNotes(t))
+
+	tm.press("down", "enter", "end")
+	tm.typeText(", coffee")
+	tm.send(autosaveMsg{id: "note-1", gen: tm.m.activeTab().edits})
+	if drafts := restoreDrafts(); len(drafts) != 1 || drafts[0].editor.Value() != "Milk\nEggs\nBread, coffee" {
+		t.Fatalf("restored %d drafts", len(drafts))
+	}
+
+	// The same profile pointed at another server has drafts of its own.
+	c, err := newAPIClient("test", profile{URL: "http://other.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = c
+	if drafts := restoreDrafts(); len(drafts) != 0 {
+		t.Errorf("restored %d drafts of another server", len(drafts))
+	}
+}
+
+func TestExportIsPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "export")
+	notes := []noteListItem{
+		{id: "note-1", title: "Diary", content: "Dear diary"},
+		{id: "note-2", title: "Locked", locked: true},
+	}
+	msg := exportCmd(notes, dir)().(exportedMsg)
+	if msg.err != nil || msg.n != 1 || msg.skipped != 1 {
+		t.Fatalf("export: %+v", msg)
+	}
+
+	// Exports hold decrypted content, so only the user may read them.
+	for path, want := range map[string]os.FileMode{dir: 0o700, filepath.Join(dir, "diary.md"): 0o600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+}